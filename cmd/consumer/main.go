@@ -12,6 +12,7 @@ import (
 
 	"github.com/CyberwizD/Distributed-Notification-System/services/push_service/internal/config"
 	"github.com/CyberwizD/Distributed-Notification-System/services/push_service/internal/consumer"
+	"github.com/CyberwizD/Distributed-Notification-System/services/push_service/internal/publisher"
 	"github.com/CyberwizD/Distributed-Notification-System/services/push_service/internal/repository"
 	"github.com/CyberwizD/Distributed-Notification-System/services/push_service/internal/routes"
 	"github.com/CyberwizD/Distributed-Notification-System/services/push_service/internal/services"
@@ -49,8 +50,20 @@ func main() {
 	statusStore := repository.NewStatusStore(db, cfg.StatusTable)
 	statusUpdater := services.NewStatusUpdater(statusStore, logr)
 
-	templateClient := services.NewTemplateClient(cfg.TemplateServiceURL, cfg.ProviderTimeout)
-	fcmProvider := services.NewFCMProvider(cfg.FCMServerKey, cfg.FCMEndpoint, cfg.ProviderTimeout, logr)
+	var templateCache *services.TemplateCache
+	if cfg.TemplateCacheTTL > 0 {
+		var store services.TemplateCacheStore
+		if cfg.TemplateCacheRedis && redisRepo != nil {
+			store = redisRepo
+		}
+		templateCache = services.NewTemplateCache(cfg.TemplateCacheTTL, cfg.TemplateStaleTTL, store)
+	}
+	templateClient := services.NewTemplateClient(cfg.TemplateServiceURL, cfg.ProviderTimeout, templateCache, logr)
+	registry, err := buildProviderRegistry(cfg, redisRepo, logr)
+	if err != nil {
+		logr.Error("failed to configure push providers", slog.Any("error", err))
+		os.Exit(1)
+	}
 	metricsCollector := metrics.New()
 
 	retryCfg := retry.Config{
@@ -59,23 +72,44 @@ func main() {
 		MaxBackoff:     cfg.RetryMaxBackoff,
 	}
 
+	conn, err := amqp.Dial(cfg.RabbitURL)
+	if err != nil {
+		logr.Error("failed to connect rabbitmq", slog.Any("error", err))
+		os.Exit(1)
+	}
+	defer conn.Close()
+
+	var tokenEvents services.TokenEventPublisher
+	if cfg.TokenEventsExchange != "" {
+		tokenPublisher, err := publisher.NewTokenEventPublisher(conn, cfg.TokenEventsExchange)
+		if err != nil {
+			logr.Error("failed to set up token event publisher", slog.Any("error", err))
+			os.Exit(1)
+		}
+		defer tokenPublisher.Close()
+		tokenEvents = tokenPublisher
+	}
+
 	processor := services.NewPushProcessor(
 		templateClient,
-		fcmProvider,
+		registry,
 		statusUpdater,
 		redisRepo,
+		tokenEvents,
 		metricsCollector,
 		logr,
 		retryCfg,
+		cfg.DryRun,
+		services.TruncationPolicy{
+			TruncateBody: cfg.TruncateBody,
+			Ellipsis:     cfg.TruncationEllipsis,
+		},
+		services.InlineTemplatePolicy{
+			Disabled:  !cfg.InlineTemplates,
+			Producers: cfg.InlineProducers,
+		},
 	)
 
-	conn, err := amqp.Dial(cfg.RabbitURL)
-	if err != nil {
-		logr.Error("failed to connect rabbitmq", slog.Any("error", err))
-		os.Exit(1)
-	}
-	defer conn.Close()
-
 	base := consumer.NewBaseConsumer(
 		conn,
 		cfg.PushQueue,
@@ -84,11 +118,14 @@ func main() {
 		cfg.WorkerCount,
 		logr,
 	)
-	pushConsumer := consumer.NewPushConsumer(base, processor, logr, cfg.RetryMaxAttempts)
+	staleness := services.StalenessPolicy{MaxAge: cfg.MessageMaxAge}
+	pushConsumer := consumer.NewPushConsumer(base, processor, logr, cfg.RetryMaxAttempts, staleness)
 
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
+	registry.RunBackground(ctx)
+
 	started := time.Now()
 	httpSrv := startHTTPServer(cfg.HTTPPort, metricsCollector, logr, started)
 
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"log/slog"
+
+	"github.com/CyberwizD/Distributed-Notification-System/services/push_service/internal/config"
+	"github.com/CyberwizD/Distributed-Notification-System/services/push_service/internal/repository"
+	"github.com/CyberwizD/Distributed-Notification-System/services/push_service/internal/services"
+)
+
+// buildProviderRegistry registers every configured provider. FCM, or the mock
+// provider when PROVIDER_MODE=mock, is the default for android and ios; APNs,
+// OneSignal and HMS are only used when a token names them explicitly, except
+// that APNs claims VoIP and Live Activity tokens. Expo needs no credentials
+// and claims Expo push tokens by their format.
+func buildProviderRegistry(cfg *config.Config, redisRepo *repository.RedisRepository, logr *slog.Logger) (*services.ProviderRegistry, error) {
+	registry := services.NewProviderRegistry()
+
+	if cfg.ProviderMode == "mock" {
+		// The mock stands in for FCM and, unless VAPID is configured, web push.
+		logr.Warn("using mock push provider, notifications will not be delivered")
+		mockProvider := services.NewMockProvider(cfg.MockProviderErrors, logr)
+		registry.Register(mockProvider, "android", "ios", "web")
+		registry.Alias("fcm", mockProvider.Name())
+	} else {
+		fcmProvider, err := newFCMProvider(cfg, logr)
+		if err != nil {
+			return nil, err
+		}
+		registry.Register(fcmProvider, "android", "ios")
+		registry.Alias("fcm", fcmProvider.Name())
+	}
+
+	if cfg.APNSKeyFile != "" {
+		apnsProvider, err := services.NewAPNsProvider(services.APNsConfig{
+			KeyFile:     cfg.APNSKeyFile,
+			KeyID:       cfg.APNSKeyID,
+			TeamID:      cfg.APNSTeamID,
+			Topic:       cfg.APNSTopic,
+			Host:        cfg.APNSHost,
+			Timeout:     cfg.ProviderTimeout,
+			Concurrency: cfg.ProviderConcurrency,
+		}, logr)
+		if err != nil {
+			return nil, err
+		}
+		registry.Register(apnsProvider)
+	}
+
+	if cfg.VAPIDPrivateKey != "" {
+		webPushProvider, err := services.NewWebPushProvider(services.WebPushConfig{
+			VAPIDPublicKey:  cfg.VAPIDPublicKey,
+			VAPIDPrivateKey: cfg.VAPIDPrivateKey,
+			VAPIDSubject:    cfg.VAPIDSubject,
+			Timeout:         cfg.ProviderTimeout,
+			Concurrency:     cfg.ProviderConcurrency,
+		}, redisRepo, logr)
+		if err != nil {
+			return nil, err
+		}
+		registry.Register(webPushProvider, "web")
+	}
+
+	if cfg.OneSignalAppID != "" {
+		registry.Register(services.NewOneSignalProvider(
+			cfg.OneSignalAppID,
+			cfg.OneSignalRESTKey,
+			cfg.OneSignalEndpoint,
+			cfg.ProviderTimeout,
+			cfg.ProviderConcurrency,
+			logr,
+		))
+	}
+
+	if cfg.HMSAppID != "" {
+		registry.Register(services.NewHMSProvider(services.HMSConfig{
+			AppID:        cfg.HMSAppID,
+			ClientID:     cfg.HMSClientID,
+			ClientSecret: cfg.HMSClientSecret,
+			TokenURL:     cfg.HMSTokenURL,
+			Endpoint:     cfg.HMSEndpoint,
+			Timeout:      cfg.ProviderTimeout,
+			Concurrency:  cfg.ProviderConcurrency,
+		}, logr))
+	}
+
+	registry.Register(services.NewExpoProvider(services.ExpoConfig{
+		Endpoint:        cfg.ExpoEndpoint,
+		AccessToken:     cfg.ExpoAccessToken,
+		ReceiptInterval: cfg.ExpoReceiptInterval,
+		Timeout:         cfg.ProviderTimeout,
+		Concurrency:     cfg.ProviderConcurrency,
+	}, redisRepo, logr))
+
+	for platform, chain := range cfg.ProviderFallbacks {
+		for _, name := range chain {
+			if _, ok := registry.Get(name); !ok {
+				logr.Warn("fallback provider is not configured", slog.String("platform", platform), slog.String("provider", name))
+			}
+		}
+		if err := registry.SetFallbackChain(platform, chain...); err != nil {
+			return nil, err
+		}
+	}
+
+	return registry, nil
+}
+
+func newFCMProvider(cfg *config.Config, logr *slog.Logger) (services.PushProvider, error) {
+	if cfg.FCMMode == "legacy" {
+		return services.NewFCMProvider(cfg.FCMServerKey, cfg.FCMEndpoint, cfg.ProviderTimeout, cfg.ProviderConcurrency, logr), nil
+	}
+	return services.NewFCMV1Provider(services.FCMV1Config{
+		CredentialsFile: cfg.FCMCredentialsFile,
+		ProjectID:       cfg.FCMProjectID,
+		Endpoint:        cfg.FCMV1Endpoint,
+		TokenURL:        cfg.FCMTokenURL,
+		Timeout:         cfg.ProviderTimeout,
+		Concurrency:     cfg.ProviderConcurrency,
+	}, logr)
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// anyToken scripts an error for every token without its own entry.
+const anyToken = "*"
+
+// recordedRequest is one push accepted by the fake, as returned by
+// GET /_fake/requests.
+type recordedRequest struct {
+	Provider   string          `json:"provider"`
+	Tokens     []string        `json:"tokens"`
+	Body       json.RawMessage `json:"body,omitempty"`
+	Headers    http.Header     `json:"headers"`
+	ReceivedAt time.Time       `json:"received_at"`
+}
+
+type fakeProvider struct {
+	logger *slog.Logger
+
+	mu       sync.Mutex
+	errors   map[string]string
+	requests []recordedRequest
+	nextID   int
+}
+
+func newFakeProvider(errors map[string]string, logger *slog.Logger) *fakeProvider {
+	return &fakeProvider{
+		logger: logger,
+		errors: errors,
+	}
+}
+
+func (f *fakeProvider) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /token", f.handleToken)
+	mux.HandleFunc("POST /fcm/send", f.handleFCMLegacy)
+	mux.HandleFunc("POST /v1/projects/{project}/messages:send", f.handleFCMV1)
+	mux.HandleFunc("POST /3/device/{token}", f.handleAPNs)
+	mux.HandleFunc("POST /webpush/{id}", f.handleWebPush)
+	mux.HandleFunc("GET /_fake/requests", f.handleListRequests)
+	mux.HandleFunc("DELETE /_fake/requests", f.handleReset)
+	mux.HandleFunc("PUT /_fake/errors", f.handleScript)
+	return mux
+}
+
+// record stores the request and returns the scripted error code per token.
+func (f *fakeProvider) record(provider string, r *http.Request, body []byte, tokens ...string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	rec := recordedRequest{
+		Provider:   provider,
+		Tokens:     tokens,
+		Headers:    r.Header.Clone(),
+		ReceivedAt: time.Now(),
+	}
+	if json.Valid(body) {
+		rec.Body = body
+	}
+	f.requests = append(f.requests, rec)
+
+	codes := make([]string, len(tokens))
+	for i, token := range tokens {
+		code, ok := f.errors[token]
+		if !ok {
+			code = f.errors[anyToken]
+		}
+		codes[i] = code
+	}
+	f.logger.Info("push received", slog.String("provider", provider), slog.Int("tokens", len(tokens)))
+	return codes
+}
+
+func (f *fakeProvider) messageID() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.nextID++
+	return fmt.Sprintf("fake-%d", f.nextID)
+}
+
+// handleToken answers OAuth2 token requests, such as the FCM v1 JWT bearer grant.
+func (f *fakeProvider) handleToken(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": "fake-access-token",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+	})
+}
+
+func (f *fakeProvider) handleFCMLegacy(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	var req struct {
+		RegistrationIDs []string `json:"registration_ids"`
+		To              string   `json:"to"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	tokens := req.RegistrationIDs
+	if req.To != "" {
+		tokens = append(tokens, req.To)
+	}
+
+	codes := f.record("fcm", r, body, tokens...)
+	if status, ok := requestStatus(codes); ok {
+		w.WriteHeader(status)
+		return
+	}
+
+	type result struct {
+		MessageID string `json:"message_id,omitempty"`
+		Error     string `json:"error,omitempty"`
+	}
+	resp := struct {
+		MulticastID int64    `json:"multicast_id"`
+		Success     int      `json:"success"`
+		Failure     int      `json:"failure"`
+		Results     []result `json:"results"`
+	}{MulticastID: time.Now().UnixNano()}
+	for _, code := range codes {
+		if code != "" {
+			resp.Failure++
+			resp.Results = append(resp.Results, result{Error: code})
+			continue
+		}
+		resp.Success++
+		resp.Results = append(resp.Results, result{MessageID: f.messageID()})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// fcmV1Statuses maps FCM v1 error codes to the HTTP status FCM answers with.
+var fcmV1Statuses = map[string]int{
+	"INVALID_ARGUMENT":       http.StatusBadRequest,
+	"UNREGISTERED":           http.StatusNotFound,
+	"SENDER_ID_MISMATCH":     http.StatusForbidden,
+	"QUOTA_EXCEEDED":         http.StatusTooManyRequests,
+	"UNAVAILABLE":            http.StatusServiceUnavailable,
+	"INTERNAL":               http.StatusInternalServerError,
+	"THIRD_PARTY_AUTH_ERROR": http.StatusUnauthorized,
+}
+
+func (f *fakeProvider) handleFCMV1(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	var req struct {
+		Message struct {
+			Token string `json:"token"`
+		} `json:"message"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	code := f.record("fcm-v1", r, body, req.Message.Token)[0]
+	if status, ok := httpCodeStatus(code); ok {
+		w.WriteHeader(status)
+		return
+	}
+	if code == "" {
+		writeJSON(w, http.StatusOK, map[string]string{
+			"name": fmt.Sprintf("projects/%s/messages/%s", r.PathValue("project"), f.messageID()),
+		})
+		return
+	}
+
+	status, ok := fcmV1Statuses[code]
+	if !ok {
+		status = http.StatusBadRequest
+	}
+	details := []map[string]interface{}{{
+		"@type":     "type.googleapis.com/google.firebase.fcm.v1.FcmError",
+		"errorCode": code,
+	}}
+	if code == "INVALID_ARGUMENT" {
+		// Scripted failures are per token, so blame the token as FCM does.
+		details = append(details, map[string]interface{}{
+			"@type":           "type.googleapis.com/google.rpc.BadRequest",
+			"fieldViolations": []map[string]string{{"field": "message.token"}},
+		})
+	}
+	writeJSON(w, status, map[string]interface{}{
+		"error": map[string]interface{}{
+			"code":    status,
+			"message": "scripted failure",
+			"status":  code,
+			"details": details,
+		},
+	})
+}
+
+// apnsStatuses maps APNs reasons to the HTTP status APNs answers with.
+var apnsStatuses = map[string]int{
+	"Unregistered":         http.StatusGone,
+	"PayloadTooLarge":      http.StatusRequestEntityTooLarge,
+	"TooManyRequests":      http.StatusTooManyRequests,
+	"InternalServerError":  http.StatusInternalServerError,
+	"ServiceUnavailable":   http.StatusServiceUnavailable,
+	"Shutdown":             http.StatusServiceUnavailable,
+	"ExpiredProviderToken": http.StatusForbidden,
+	"InvalidProviderToken": http.StatusForbidden,
+}
+
+func (f *fakeProvider) handleAPNs(w http.ResponseWriter, r *http.Request) {
+	if r.ProtoMajor != 2 {
+		http.Error(w, "APNs requires HTTP/2", http.StatusHTTPVersionNotSupported)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+
+	code := f.record("apns", r, body, r.PathValue("token"))[0]
+	if status, ok := httpCodeStatus(code); ok {
+		w.WriteHeader(status)
+		return
+	}
+	if code == "" {
+		w.Header().Set("apns-id", f.messageID())
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	status, ok := apnsStatuses[code]
+	if !ok {
+		status = http.StatusBadRequest
+	}
+	resp := map[string]interface{}{"reason": code}
+	if status == http.StatusGone {
+		resp["timestamp"] = time.Now().UnixMilli()
+	}
+	writeJSON(w, status, resp)
+}
+
+func (f *fakeProvider) handleWebPush(w http.ResponseWriter, r *http.Request) {
+	// The body is aes128gcm ciphertext, so only the headers are recorded.
+	_, _ = io.Copy(io.Discard, r.Body)
+
+	code := f.record("webpush", r, nil, r.PathValue("id"))[0]
+	if status, ok := httpCodeStatus(code); ok {
+		w.WriteHeader(status)
+		return
+	}
+	switch code {
+	case "":
+		w.Header().Set("Location", "/webpush/messages/"+f.messageID())
+		w.WriteHeader(http.StatusCreated)
+	case "SubscriptionGone":
+		w.WriteHeader(http.StatusGone)
+	case "PayloadTooLarge":
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+	case "TooManyRequests":
+		w.WriteHeader(http.StatusTooManyRequests)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func (f *fakeProvider) handleListRequests(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	requests := append([]recordedRequest{}, f.requests...)
+	f.mu.Unlock()
+	writeJSON(w, http.StatusOK, requests)
+}
+
+func (f *fakeProvider) handleReset(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.requests = nil
+	f.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleScript merges a {"token": "Code"} object into the scripted errors; an
+// empty code clears the token's entry.
+func (f *fakeProvider) handleScript(w http.ResponseWriter, r *http.Request) {
+	var scripted map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&scripted); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	f.mu.Lock()
+	for token, code := range scripted {
+		if code == "" {
+			delete(f.errors, token)
+			continue
+		}
+		f.errors[token] = code
+	}
+	f.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// httpCodeStatus turns a scripted "HTTP_503" style code into a bare status
+// response, the way a provider fails before producing a per-token result.
+func httpCodeStatus(code string) (int, bool) {
+	raw, ok := strings.CutPrefix(code, "HTTP_")
+	if !ok {
+		return 0, false
+	}
+	status, err := strconv.Atoi(raw)
+	if err != nil || status < 100 || status > 599 {
+		return 0, false
+	}
+	return status, true
+}
+
+// requestStatus fails a whole multi-token request when any token is scripted
+// with an HTTP_ code.
+func requestStatus(codes []string) (int, bool) {
+	for _, code := range codes {
+		if status, ok := httpCodeStatus(code); ok {
+			return status, true
+		}
+	}
+	return 0, false
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
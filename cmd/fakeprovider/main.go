@@ -0,0 +1,81 @@
+// Command fakeprovider is an offline stand-in for FCM (legacy and v1), APNs and
+// Web Push used by integration tests. Point the consumer at it with e.g.
+//
+//	FCM_ENDPOINT=http://localhost:4000/fcm/send
+//	FCM_V1_ENDPOINT=http://localhost:4000/v1 FCM_TOKEN_URL=http://localhost:4000/token
+//	APNS_HOST=http://localhost:4000
+//
+// and register web push subscriptions with endpoints under
+// http://localhost:4000/webpush/. Every accepted request is recorded and can be
+// read back from /_fake/requests; per-token failures are scripted with
+// FAKEPROVIDER_ERRORS="token=Code;token=Code" or PUT /_fake/errors.
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/CyberwizD/Distributed-Notification-System/services/push_service/pkg/logger"
+)
+
+func main() {
+	addr := getEnv("FAKEPROVIDER_ADDR", ":4000")
+	logr := logger.New(getEnv("LOG_LEVEL", "info"))
+
+	fake := newFakeProvider(parseErrors(os.Getenv("FAKEPROVIDER_ERRORS")), logr)
+
+	// APNs is HTTP/2 only, so cleartext HTTP/2 (h2c) is served alongside HTTP/1.1.
+	var protocols http.Protocols
+	protocols.SetHTTP1(true)
+	protocols.SetUnencryptedHTTP2(true)
+	srv := &http.Server{
+		Addr:      addr,
+		Handler:   fake.routes(),
+		Protocols: &protocols,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		logr.Info("fake provider listening", slog.String("addr", addr))
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("fake provider: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logr.Error("failed to shutdown fake provider", slog.Any("error", err))
+	}
+}
+
+func getEnv(key, def string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return def
+}
+
+// parseErrors parses "token=Code;token=Code".
+func parseErrors(raw string) map[string]string {
+	scripted := make(map[string]string)
+	for _, entry := range strings.Split(raw, ";") {
+		token, code, found := strings.Cut(strings.TrimSpace(entry), "=")
+		if !found {
+			continue
+		}
+		scripted[strings.TrimSpace(token)] = strings.TrimSpace(code)
+	}
+	return scripted
+}
@@ -5,6 +5,7 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -22,12 +23,50 @@ type Config struct {
 	PrefetchCount       int
 	WorkerCount         int
 	TemplateServiceURL  string
+	TemplateCacheTTL    time.Duration
+	TemplateStaleTTL    time.Duration
+	TemplateCacheRedis  bool
+	InlineTemplates     bool
+	InlineProducers     []string
 	DatabaseURL         string
 	RedisURL            string
 	StatusTable         string
+	FCMMode             string
 	FCMServerKey        string
 	FCMEndpoint         string
+	FCMCredentialsFile  string
+	FCMProjectID        string
+	FCMV1Endpoint       string
+	FCMTokenURL         string
+	APNSKeyFile         string
+	APNSKeyID           string
+	APNSTeamID          string
+	APNSTopic           string
+	APNSHost            string
+	VAPIDPublicKey      string
+	VAPIDPrivateKey     string
+	VAPIDSubject        string
+	OneSignalAppID      string
+	OneSignalRESTKey    string
+	OneSignalEndpoint   string
+	ProviderFallbacks   map[string][]string
+	TokenEventsExchange string
+	HMSAppID            string
+	HMSClientID         string
+	HMSClientSecret     string
+	HMSTokenURL         string
+	HMSEndpoint         string
+	ExpoEndpoint        string
+	ExpoAccessToken     string
+	ExpoReceiptInterval time.Duration
+	ProviderMode        string
+	MockProviderErrors  map[string]string
+	DryRun              bool
+	MessageMaxAge       time.Duration
+	TruncateBody        bool
+	TruncationEllipsis  string
 	ProviderTimeout     time.Duration
+	ProviderConcurrency int
 	RetryMaxAttempts    int
 	RetryInitialBackoff time.Duration
 	RetryMaxBackoff     time.Duration
@@ -48,12 +87,50 @@ func Load() (*Config, error) {
 		PrefetchCount:       getEnvAsInt("PUSH_PREFETCH", 100),
 		WorkerCount:         getEnvAsInt("WORKER_COUNT", 5),
 		TemplateServiceURL:  getEnv("TEMPLATE_SERVICE_URL", ""),
+		TemplateCacheTTL:    getEnvAsDuration("TEMPLATE_CACHE_TTL", 5*time.Minute),
+		TemplateStaleTTL:    getEnvAsDuration("TEMPLATE_CACHE_STALE_TTL", time.Hour),
+		TemplateCacheRedis:  getEnvAsBool("TEMPLATE_CACHE_REDIS", false),
+		InlineTemplates:     getEnvAsBool("INLINE_TEMPLATES", true),
+		InlineProducers:     getEnvAsList("INLINE_TEMPLATE_PRODUCERS"),
 		DatabaseURL:         getEnv("DATABASE_URL", ""),
 		RedisURL:            getEnv("REDIS_URL", ""),
 		StatusTable:         getEnv("STATUS_TABLE", "notification_statuses"),
+		FCMMode:             getEnv("FCM_MODE", "v1"),
 		FCMServerKey:        getEnv("FCM_SERVER_KEY", ""),
 		FCMEndpoint:         getEnv("FCM_ENDPOINT", "https://fcm.googleapis.com/fcm/send"),
+		FCMCredentialsFile:  getEnv("FCM_CREDENTIALS_FILE", os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")),
+		FCMProjectID:        getEnv("FCM_PROJECT_ID", ""),
+		FCMV1Endpoint:       getEnv("FCM_V1_ENDPOINT", "https://fcm.googleapis.com/v1"),
+		FCMTokenURL:         getEnv("FCM_TOKEN_URL", ""),
+		APNSKeyFile:         getEnv("APNS_KEY_FILE", ""),
+		APNSKeyID:           getEnv("APNS_KEY_ID", ""),
+		APNSTeamID:          getEnv("APNS_TEAM_ID", ""),
+		APNSTopic:           getEnv("APNS_TOPIC", ""),
+		APNSHost:            getEnv("APNS_HOST", "production"),
+		VAPIDPublicKey:      getEnv("VAPID_PUBLIC_KEY", ""),
+		VAPIDPrivateKey:     getEnv("VAPID_PRIVATE_KEY", ""),
+		VAPIDSubject:        getEnv("VAPID_SUBJECT", ""),
+		OneSignalAppID:      getEnv("ONESIGNAL_APP_ID", ""),
+		OneSignalRESTKey:    getEnv("ONESIGNAL_REST_KEY", ""),
+		OneSignalEndpoint:   getEnv("ONESIGNAL_ENDPOINT", "https://onesignal.com/api/v1/notifications"),
+		ProviderFallbacks:   getEnvAsChains("PROVIDER_FALLBACKS"),
+		TokenEventsExchange: getEnv("TOKEN_EVENTS_EXCHANGE", "push.token_events"),
+		HMSAppID:            getEnv("HMS_APP_ID", ""),
+		HMSClientID:         getEnv("HMS_CLIENT_ID", ""),
+		HMSClientSecret:     getEnv("HMS_CLIENT_SECRET", ""),
+		HMSTokenURL:         getEnv("HMS_TOKEN_URL", "https://oauth-login.cloud.huawei.com/oauth2/v3/token"),
+		HMSEndpoint:         getEnv("HMS_ENDPOINT", "https://push-api.cloud.huawei.com/v1"),
+		ExpoEndpoint:        getEnv("EXPO_ENDPOINT", "https://exp.host/--/api/v2/push"),
+		ExpoAccessToken:     getEnv("EXPO_ACCESS_TOKEN", ""),
+		ExpoReceiptInterval: getEnvAsDuration("EXPO_RECEIPT_INTERVAL", 15*time.Minute),
+		ProviderMode:        strings.ToLower(getEnv("PROVIDER_MODE", "live")),
+		MockProviderErrors:  getEnvAsMap("MOCK_PROVIDER_ERRORS"),
+		DryRun:              getEnvAsBool("DRY_RUN", false),
+		MessageMaxAge:       getEnvAsDuration("MESSAGE_MAX_AGE", 0),
+		TruncateBody:        getEnvAsBool("PAYLOAD_TRUNCATE_BODY", true),
+		TruncationEllipsis:  getEnv("PAYLOAD_TRUNCATION_ELLIPSIS", "…"),
 		ProviderTimeout:     getEnvAsDuration("PROVIDER_TIMEOUT", 10*time.Second),
+		ProviderConcurrency: getEnvAsInt("PROVIDER_CONCURRENCY", 8),
 		RetryMaxAttempts:    getEnvAsInt("RETRY_MAX_ATTEMPTS", 4),
 		RetryInitialBackoff: getEnvAsDuration("RETRY_INITIAL_BACKOFF", time.Second),
 		RetryMaxBackoff:     getEnvAsDuration("RETRY_MAX_BACKOFF", 15*time.Second),
@@ -76,8 +153,44 @@ func (c *Config) validate() error {
 	if c.TemplateServiceURL == "" {
 		missing = append(missing, "TEMPLATE_SERVICE_URL")
 	}
-	if c.FCMServerKey == "" {
-		missing = append(missing, "FCM_SERVER_KEY")
+	switch c.ProviderMode {
+	case "live":
+		switch c.FCMMode {
+		case "legacy":
+			if c.FCMServerKey == "" {
+				missing = append(missing, "FCM_SERVER_KEY")
+			}
+		case "v1":
+			if c.FCMCredentialsFile == "" {
+				missing = append(missing, "FCM_CREDENTIALS_FILE")
+			}
+		default:
+			return fmt.Errorf("invalid FCM_MODE %q: expected legacy or v1", c.FCMMode)
+		}
+	case "mock":
+		// The mock provider replaces FCM, so no FCM credentials are needed.
+	default:
+		return fmt.Errorf("invalid PROVIDER_MODE %q: expected live or mock", c.ProviderMode)
+	}
+	if c.APNSKeyFile != "" {
+		if c.APNSKeyID == "" {
+			missing = append(missing, "APNS_KEY_ID")
+		}
+		if c.APNSTeamID == "" {
+			missing = append(missing, "APNS_TEAM_ID")
+		}
+		if c.APNSTopic == "" {
+			missing = append(missing, "APNS_TOPIC")
+		}
+	}
+	if c.VAPIDPrivateKey != "" && c.VAPIDSubject == "" {
+		missing = append(missing, "VAPID_SUBJECT")
+	}
+	if c.OneSignalAppID != "" && c.OneSignalRESTKey == "" {
+		missing = append(missing, "ONESIGNAL_REST_KEY")
+	}
+	if c.HMSAppID != "" && c.HMSClientSecret == "" {
+		missing = append(missing, "HMS_CLIENT_SECRET")
 	}
 	if len(missing) > 0 {
 		return fmt.Errorf("missing required environment variables: %v", missing)
@@ -105,6 +218,18 @@ func getEnvAsInt(key string, def int) int {
 	return def
 }
 
+func getEnvAsBool(key string, def bool) bool {
+	if value, ok := os.LookupEnv(key); ok {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			log.Printf("invalid bool for %s, using default %t: %v", key, def, err)
+			return def
+		}
+		return b
+	}
+	return def
+}
+
 func getEnvAsDuration(key string, def time.Duration) time.Duration {
 	if value, ok := os.LookupEnv(key); ok {
 		d, err := time.ParseDuration(value)
@@ -116,3 +241,65 @@ func getEnvAsDuration(key string, def time.Duration) time.Duration {
 	}
 	return def
 }
+
+// getEnvAsList parses a comma separated list, skipping empty entries.
+func getEnvAsList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// getEnvAsChains parses "platform=provider,provider;platform=provider" into
+// ordered provider chains keyed by platform.
+func getEnvAsChains(key string) map[string][]string {
+	chains := make(map[string][]string)
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return chains
+	}
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		platform, list, found := strings.Cut(entry, "=")
+		if !found {
+			log.Printf("invalid chain %q for %s, expected platform=provider,provider", entry, key)
+			continue
+		}
+		var chain []string
+		for _, name := range strings.Split(list, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				chain = append(chain, name)
+			}
+		}
+		chains[strings.ToLower(strings.TrimSpace(platform))] = chain
+	}
+	return chains
+}
+
+// getEnvAsMap parses "key=value;key=value" into a map.
+func getEnvAsMap(key string) map[string]string {
+	values := make(map[string]string)
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return values
+	}
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		k, v, found := strings.Cut(entry, "=")
+		if !found {
+			log.Printf("invalid entry %q for %s, expected key=value", entry, key)
+			continue
+		}
+		values[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return values
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"log/slog"
+	"time"
 
 	"github.com/CyberwizD/Distributed-Notification-System/services/push_service/internal/models"
 	"github.com/CyberwizD/Distributed-Notification-System/services/push_service/internal/services"
@@ -15,9 +16,10 @@ type PushConsumer struct {
 	processor     *services.PushProcessor
 	logger        *slog.Logger
 	maxDeliveries int
+	staleness     services.StalenessPolicy
 }
 
-func NewPushConsumer(base *BaseConsumer, processor *services.PushProcessor, logger *slog.Logger, maxDeliveries int) *PushConsumer {
+func NewPushConsumer(base *BaseConsumer, processor *services.PushProcessor, logger *slog.Logger, maxDeliveries int, staleness services.StalenessPolicy) *PushConsumer {
 	if maxDeliveries <= 0 {
 		maxDeliveries = 5
 	}
@@ -26,6 +28,7 @@ func NewPushConsumer(base *BaseConsumer, processor *services.PushProcessor, logg
 		processor:     processor,
 		logger:        logger,
 		maxDeliveries: maxDeliveries,
+		staleness:     staleness,
 	}
 }
 
@@ -41,12 +44,20 @@ func (p *PushConsumer) handleDelivery(ctx context.Context, msg amqp.Delivery) er
 		return err
 	}
 
-	if err := p.processor.Process(ctx, &envelope); err != nil {
-		requeue := p.shouldRetry(&msg)
+	// Stale messages are acknowledged, not dead-lettered: they are not broken,
+	// just no longer worth sending.
+	if reason, expired := p.staleness.Expired(&envelope, time.Now()); expired {
+		p.processor.Expire(ctx, &envelope, reason)
+		return msg.Ack(false)
+	}
+
+	if err := p.processor.Process(ctx, &envelope, producer(&msg)); err != nil {
+		requeue := p.shouldRetry(&msg, err)
+		class := services.ClassOf(err).String()
 		if requeue {
-			p.logger.Warn("processing failed, message requeued", slog.String("request_id", envelope.RequestID), slog.Any("error", err))
+			p.logger.Warn("processing failed, message requeued", slog.String("request_id", envelope.RequestID), slog.String("class", class), slog.Any("error", err))
 		} else {
-			p.logger.Error("processing failed, message dead-lettered", slog.String("request_id", envelope.RequestID), slog.Any("error", err))
+			p.logger.Error("processing failed, message dead-lettered", slog.String("request_id", envelope.RequestID), slog.String("class", class), slog.Any("error", err))
 		}
 		_ = msg.Nack(false, requeue)
 		return err
@@ -55,7 +66,22 @@ func (p *PushConsumer) handleDelivery(ctx context.Context, msg amqp.Delivery) er
 	return msg.Ack(false)
 }
 
-func (p *PushConsumer) shouldRetry(msg *amqp.Delivery) bool {
+// producer identifies the publisher of msg. RabbitMQ rejects a user-id
+// property that does not match the publishing connection's user, so it is
+// preferred over the app-id, which only the publisher vouches for.
+func producer(msg *amqp.Delivery) string {
+	if msg.UserId != "" {
+		return msg.UserId
+	}
+	return msg.AppId
+}
+
+// shouldRetry requeues only retryable failures; token- and message-fatal
+// failures go straight to the dead-letter queue.
+func (p *PushConsumer) shouldRetry(msg *amqp.Delivery, err error) bool {
+	if services.ClassOf(err) != services.ErrorRetryable {
+		return false
+	}
 	attempts := deliveryAttempts(msg)
 	return attempts < p.maxDeliveries
 }
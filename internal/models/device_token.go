@@ -4,9 +4,11 @@ import "strings"
 
 // DeviceToken represents a user device that can receive push notifications.
 type DeviceToken struct {
-	Token    string `json:"token"`
-	Platform string `json:"platform"`
-	Provider string `json:"provider,omitempty"`
+	Token    string       `json:"token"`
+	Platform string       `json:"platform"`
+	Provider string       `json:"provider,omitempty"`
+	Type     string       `json:"type,omitempty"`
+	Keys     *WebPushKeys `json:"keys,omitempty"`
 }
 
 // PlatformCategory normalizes a platform string to one of the supported categories.
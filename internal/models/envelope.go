@@ -4,15 +4,43 @@ import "time"
 
 // MessageEnvelope is the payload produced by the API gateway and consumed by the push service.
 type MessageEnvelope struct {
-	RequestID         string                 `json:"request_id"`
-	CorrelationID     string                 `json:"correlation_id"`
-	CreatedAt         time.Time              `json:"created_at"`
-	Channel           string                 `json:"channel"`
-	User              User                   `json:"user"`
-	Template          Template               `json:"template"`
-	Variables         map[string]interface{} `json:"variables"`
+	RequestID     string                 `json:"request_id"`
+	CorrelationID string                 `json:"correlation_id"`
+	CreatedAt     time.Time              `json:"created_at"`
+	Channel       string                 `json:"channel"`
+	User          User                   `json:"user"`
+	Template      Template               `json:"template"`
+	Variables     map[string]interface{} `json:"variables"`
+	// ProviderOverrides are merged into the request of the provider named by
+	// the key ("fcm", "fcm-v1", "apns", ...). FCM v1 only reads "fcm-v1".
 	ProviderOverrides map[string]interface{} `json:"provider_overrides,omitempty"`
-	RetryCount        int                    `json:"retry_count"`
+	// OptionalDataKeys lists variables that may be dropped, in order, when
+	// the payload exceeds a provider's size limit.
+	OptionalDataKeys []string `json:"optional_data_keys,omitempty"`
+	// Notification overrides the template's presentation fields.
+	Notification *NotificationOptions `json:"notification,omitempty"`
+	RetryCount   int                  `json:"retry_count"`
+	// Kind is "alert" (the default), "data" for a silent data-only message,
+	// "background" for a content-available wake-up, or the APNs only
+	// "liveactivity" and "voip".
+	Kind string `json:"kind,omitempty"`
+	// LiveActivity is required when Kind is "liveactivity".
+	LiveActivity *LiveActivity `json:"live_activity,omitempty"`
+	// TTLSeconds is how long providers keep trying to deliver; zero leaves the
+	// provider default.
+	TTLSeconds int `json:"ttl_seconds,omitempty"`
+	// CollapseKey makes a newer message replace an undelivered or displayed
+	// one with the same key.
+	CollapseKey string `json:"collapse_key,omitempty"`
+	// Priority is "high" or "normal"; empty leaves the provider default.
+	Priority string `json:"priority,omitempty"`
+	// ExpiresAt drops the message unsent when it is consumed after this time.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// MaxAge in seconds since CreatedAt overrides the service-wide maximum age.
+	MaxAge int `json:"max_age,omitempty"`
+	// DryRun runs the full pipeline but asks providers to validate only, so
+	// no device is notified.
+	DryRun bool `json:"dry_run,omitempty"`
 }
 
 type User struct {
@@ -26,14 +54,37 @@ type PushToken struct {
 	Token    string `json:"token"`
 	Platform string `json:"platform"`
 	Provider string `json:"provider,omitempty"`
+	// Type is "device" (the default), "voip" for a PushKit token or
+	// "liveactivity" for a Live Activity token.
+	Type string `json:"type,omitempty"`
+	// Keys carries the browser subscription keys for web tokens, whose Token is
+	// the push service endpoint URL.
+	Keys *WebPushKeys `json:"keys,omitempty"`
 }
 
+// Token types.
+const (
+	TokenTypeDevice       = "device"
+	TokenTypeVoIP         = "voip"
+	TokenTypeLiveActivity = "liveactivity"
+)
+
+// WebPushKeys are the base64url encoded keys from a browser PushSubscription.
+type WebPushKeys struct {
+	P256dh string `json:"p256dh"`
+	Auth   string `json:"auth"`
+}
+
+// Template references a stored template by slug, locale and version, or
+// carries its content inline when Subject or Body is set.
 type Template struct {
 	Slug    string `json:"slug"`
 	Locale  string `json:"locale"`
 	Version int    `json:"version"`
 	Subject string `json:"subject,omitempty"`
 	Body    string `json:"body,omitempty"`
+	// Notification holds the presentation defaults from the template metadata.
+	Notification *NotificationOptions `json:"notification,omitempty"`
 }
 
 // RenderedTemplate is the final text after template substitution.
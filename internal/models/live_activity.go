@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Live Activity events.
+const (
+	LiveActivityStart  = "start"
+	LiveActivityUpdate = "update"
+	LiveActivityEnd    = "end"
+)
+
+// LiveActivity describes an iOS Live Activity push.
+type LiveActivity struct {
+	// Event is "start", "update" or "end".
+	Event string `json:"event"`
+	// ContentState is the activity's dynamic state, decoded by the app's
+	// ActivityAttributes.ContentState.
+	ContentState  map[string]interface{} `json:"content_state"`
+	StaleDate     *time.Time             `json:"stale_date,omitempty"`
+	DismissalDate *time.Time             `json:"dismissal_date,omitempty"`
+	// AttributesType and Attributes are required to start an activity remotely.
+	AttributesType string                 `json:"attributes_type,omitempty"`
+	Attributes     map[string]interface{} `json:"attributes,omitempty"`
+}
@@ -0,0 +1,61 @@
+package models
+
+// NotificationOptions are the presentation fields every provider understands
+// in some form. They come from template metadata and the envelope, with
+// envelope values taking precedence.
+type NotificationOptions struct {
+	ImageURL string `json:"image_url,omitempty"`
+	// ClickAction is the deep link or URL opened when the notification is tapped.
+	ClickAction string               `json:"click_action,omitempty"`
+	Actions     []NotificationAction `json:"actions,omitempty"`
+	Sound       string               `json:"sound,omitempty"`
+	// Badge is the app icon badge count; nil leaves the badge unchanged.
+	Badge *int `json:"badge,omitempty"`
+	// ChannelID is the Android notification channel.
+	ChannelID string `json:"channel_id,omitempty"`
+	// ThreadID groups related notifications (APNs thread-id, Android group).
+	ThreadID string `json:"thread_id,omitempty"`
+	// Category selects the action set registered by the iOS app.
+	Category string `json:"category,omitempty"`
+}
+
+// NotificationAction is an action button shown with the notification.
+type NotificationAction struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	// URL is opened when the action is chosen, where the platform supports it.
+	URL  string `json:"url,omitempty"`
+	Icon string `json:"icon,omitempty"`
+}
+
+// Merge returns o with every field set in override replacing its own.
+func (o NotificationOptions) Merge(override *NotificationOptions) NotificationOptions {
+	if override == nil {
+		return o
+	}
+	if override.ImageURL != "" {
+		o.ImageURL = override.ImageURL
+	}
+	if override.ClickAction != "" {
+		o.ClickAction = override.ClickAction
+	}
+	if len(override.Actions) > 0 {
+		o.Actions = override.Actions
+	}
+	if override.Sound != "" {
+		o.Sound = override.Sound
+	}
+	if override.Badge != nil {
+		o.Badge = override.Badge
+	}
+	if override.ChannelID != "" {
+		o.ChannelID = override.ChannelID
+	}
+	if override.ThreadID != "" {
+		o.ThreadID = override.ThreadID
+	}
+	if override.Category != "" {
+		o.Category = override.Category
+	}
+	return o
+}
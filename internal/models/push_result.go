@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 // PushResult captures the delivery outcome per device token.
 type PushResult struct {
 	Token     string `json:"token"`
@@ -7,6 +9,13 @@ type PushResult struct {
 	Status    string `json:"status"`
 	MessageID string `json:"message_id,omitempty"`
 	Error     string `json:"error,omitempty"`
+	// CanonicalToken is the replacement token reported by the provider when the
+	// one we sent to has been superseded.
+	CanonicalToken string `json:"canonical_token,omitempty"`
+	// UnregisteredAt is set when the provider reports when the token stopped being valid.
+	UnregisteredAt *time.Time `json:"unregistered_at,omitempty"`
+	// RetryAfter is the provider requested delay before retrying this token.
+	RetryAfter time.Duration `json:"retry_after,omitempty"`
 }
 
 const (
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// TokenRotatedEvent tells the user service that a provider replaced a device
+// token with a canonical one.
+type TokenRotatedEvent struct {
+	UserID    string    `json:"user_id"`
+	OldToken  string    `json:"old_token"`
+	NewToken  string    `json:"new_token"`
+	Platform  string    `json:"platform,omitempty"`
+	Provider  string    `json:"provider"`
+	RequestID string    `json:"request_id,omitempty"`
+	RotatedAt time.Time `json:"rotated_at"`
+}
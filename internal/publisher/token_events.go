@@ -0,0 +1,76 @@
+package publisher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/CyberwizD/Distributed-Notification-System/services/push_service/internal/models"
+	"github.com/streadway/amqp"
+)
+
+const tokenRotatedRoutingKey = "token.rotated"
+
+// TokenEventPublisher publishes device token lifecycle events to a RabbitMQ
+// topic exchange so the user service can keep its device table current.
+type TokenEventPublisher struct {
+	ch       *amqp.Channel
+	exchange string
+	mu       sync.Mutex
+}
+
+func NewTokenEventPublisher(conn *amqp.Connection, exchange string) (*TokenEventPublisher, error) {
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, err
+	}
+	if err := ch.ExchangeDeclare(
+		exchange,
+		"topic",
+		true,
+		false,
+		false,
+		false,
+		nil,
+	); err != nil {
+		_ = ch.Close()
+		return nil, fmt.Errorf("token event exchange setup failed: %w", err)
+	}
+	return &TokenEventPublisher{
+		ch:       ch,
+		exchange: exchange,
+	}, nil
+}
+
+// PublishTokenRotated publishes an old -> new token mapping.
+func (p *TokenEventPublisher) PublishTokenRotated(ctx context.Context, event models.TokenRotatedEvent) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.ch.Publish(
+		p.exchange,
+		tokenRotatedRoutingKey,
+		false,
+		false,
+		amqp.Publishing{
+			ContentType:  "application/json",
+			DeliveryMode: amqp.Persistent,
+			Timestamp:    time.Now(),
+			Type:         tokenRotatedRoutingKey,
+			Body:         body,
+		},
+	)
+}
+
+func (p *TokenEventPublisher) Close() error {
+	return p.ch.Close()
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"strconv"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -45,3 +46,88 @@ func (r *RedisRepository) SuppressToken(ctx context.Context, token string, ttl t
 	key := "push:token:suppressed:" + token
 	return r.client.SetEX(ctx, key, "1", ttl).Err()
 }
+
+// GetTemplate returns a cached template entry, or nil when there is none.
+func (r *RedisRepository) GetTemplate(ctx context.Context, key string) ([]byte, error) {
+	value, err := r.client.Get(ctx, "push:template:"+key).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	return value, err
+}
+
+// SetTemplate caches a template entry for ttl.
+func (r *RedisRepository) SetTemplate(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return r.client.SetEX(ctx, "push:template:"+key, value, ttl).Err()
+}
+
+const (
+	expoTicketIndexKey = "push:expo:tickets"
+	expoTicketKey      = "push:expo:ticket:"
+)
+
+// TrackExpoTicket remembers which token an Expo push ticket belongs to until
+// its receipt is checked, for at most ttl.
+func (r *RedisRepository) TrackExpoTicket(ctx context.Context, id, token string, issued time.Time, ttl time.Duration) error {
+	pipe := r.client.TxPipeline()
+	pipe.SetEX(ctx, expoTicketKey+id, token, ttl)
+	pipe.ZAdd(ctx, expoTicketIndexKey, &redis.Z{Score: float64(issued.Unix()), Member: id})
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// DueExpoTickets returns up to limit tracked tickets issued before the given
+// time, as ticket id to token. Tickets whose entry expired are dropped.
+func (r *RedisRepository) DueExpoTickets(ctx context.Context, before time.Time, limit int64) (map[string]string, error) {
+	ids, err := r.client.ZRangeByScore(ctx, expoTicketIndexKey, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   "(" + strconv.FormatInt(before.Unix(), 10),
+		Count: limit,
+	}).Result()
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = expoTicketKey + id
+	}
+	tokens, err := r.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	due := make(map[string]string, len(ids))
+	var expired []interface{}
+	for i, id := range ids {
+		if token, ok := tokens[i].(string); ok {
+			due[id] = token
+		} else {
+			expired = append(expired, id)
+		}
+	}
+	if len(expired) > 0 {
+		if err := r.client.ZRem(ctx, expoTicketIndexKey, expired...).Err(); err != nil {
+			return due, err
+		}
+	}
+	return due, nil
+}
+
+// ForgetExpoTickets stops tracking tickets whose receipts were checked.
+func (r *RedisRepository) ForgetExpoTickets(ctx context.Context, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	keys := make([]string, len(ids))
+	members := make([]interface{}, len(ids))
+	for i, id := range ids {
+		keys[i] = expoTicketKey + id
+		members[i] = id
+	}
+	pipe := r.client.TxPipeline()
+	pipe.Del(ctx, keys...)
+	pipe.ZRem(ctx, expoTicketIndexKey, members...)
+	_, err := pipe.Exec(ctx)
+	return err
+}
@@ -15,6 +15,8 @@ type NotificationStatus struct {
 	UpdatedAt time.Time
 	Provider  string
 	Detail    string
+	// TemplateVersion is the template version the message was rendered from.
+	TemplateVersion int
 }
 
 type StatusStore struct {
@@ -46,9 +48,25 @@ func (s *StatusStore) UpdateStatus(ctx context.Context, requestID, status, provi
 		Provider:  provider,
 		Detail:    detail,
 	}
+	return s.upsert(ctx, &ns, "status", "updated_at", "provider", "detail")
+}
+
+// UpdateProcessing marks the request as being processed with the resolved
+// template version. Later status updates keep the version.
+func (s *StatusStore) UpdateProcessing(ctx context.Context, requestID, status string, templateVersion int) error {
+	ns := NotificationStatus{
+		RequestID:       requestID,
+		Status:          status,
+		UpdatedAt:       time.Now(),
+		TemplateVersion: templateVersion,
+	}
+	return s.upsert(ctx, &ns, "status", "updated_at", "provider", "detail", "template_version")
+}
+
+func (s *StatusStore) upsert(ctx context.Context, ns *NotificationStatus, columns ...string) error {
 	return s.db.WithContext(ctx).Table(s.tableName).
 		Clauses(clause.OnConflict{
 			Columns:   []clause.Column{{Name: "request_id"}},
-			DoUpdates: clause.AssignmentColumns([]string{"status", "updated_at", "provider", "detail"}),
-		}).Create(&ns).Error
+			DoUpdates: clause.AssignmentColumns(columns),
+		}).Create(ns).Error
 }
@@ -0,0 +1,420 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CyberwizD/Distributed-Notification-System/services/push_service/internal/models"
+)
+
+const (
+	apnsProductionHost = "https://api.push.apple.com"
+	apnsSandboxHost    = "https://api.sandbox.push.apple.com"
+	// apnsTokenLifetime keeps provider tokens comfortably inside Apple's one
+	// hour limit while staying above the 20 minute minimum refresh interval.
+	apnsTokenLifetime = 50 * time.Minute
+	// apnsMaxPayloadBytes and apnsMaxVoIPPayloadBytes are Apple's payload limits.
+	apnsMaxPayloadBytes     = 4096
+	apnsMaxVoIPPayloadBytes = 5120
+)
+
+// APNsConfig configures the APNs provider.
+type APNsConfig struct {
+	KeyFile string
+	KeyID   string
+	TeamID  string
+	Topic   string
+	// Host is "production", "sandbox" or a full base URL (e.g. a local fake).
+	Host    string
+	Timeout time.Duration
+	// Concurrency bounds the number of in-flight per-token requests.
+	Concurrency int
+}
+
+// APNsProvider sends notifications directly to Apple Push Notification service
+// over HTTP/2 using token-based (.p8) authentication.
+type APNsProvider struct {
+	keyID       string
+	teamID      string
+	topic       string
+	host        string
+	key         *ecdsa.PrivateKey
+	client      *http.Client
+	logger      *slog.Logger
+	concurrency int
+
+	mu        sync.Mutex
+	jwt       string
+	jwtIssued time.Time
+}
+
+func NewAPNsProvider(cfg APNsConfig, logger *slog.Logger) (*APNsProvider, error) {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.KeyID == "" || cfg.TeamID == "" {
+		return nil, fmt.Errorf("apns: key id and team id are required")
+	}
+
+	raw, err := os.ReadFile(cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("apns: read key: %w", err)
+	}
+	key, err := parseECPrivateKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("apns: %w", err)
+	}
+
+	host := apnsHost(cfg.Host)
+	transport := &http.Transport{ForceAttemptHTTP2: true}
+	if strings.HasPrefix(host, "http://") {
+		// Local fakes speak HTTP/2 without TLS (h2c).
+		protocols := new(http.Protocols)
+		protocols.SetUnencryptedHTTP2(true)
+		transport.Protocols = protocols
+	}
+
+	return &APNsProvider{
+		keyID:  cfg.KeyID,
+		teamID: cfg.TeamID,
+		topic:  cfg.Topic,
+		host:   host,
+		key:    key,
+		client: &http.Client{
+			Timeout:   cfg.Timeout,
+			Transport: transport,
+		},
+		logger:      logger,
+		concurrency: cfg.Concurrency,
+	}, nil
+}
+
+func apnsHost(host string) string {
+	switch strings.ToLower(host) {
+	case "", "production":
+		return apnsProductionHost
+	case "sandbox", "development":
+		return apnsSandboxHost
+	default:
+		return strings.TrimRight(host, "/")
+	}
+}
+
+func (p *APNsProvider) Name() string {
+	return "apns"
+}
+
+// Claims routes VoIP and Live Activity tokens to APNs; only it can send them.
+func (p *APNsProvider) Claims(token models.PushToken) bool {
+	return token.Type == models.TokenTypeVoIP || token.Type == models.TokenTypeLiveActivity
+}
+
+// SupportsKind reports that APNs sends every message kind.
+func (p *APNsProvider) SupportsKind(kind MessageKind) bool {
+	return true
+}
+
+func (p *APNsProvider) Send(ctx context.Context, payload *PushPayload) ([]models.PushResult, error) {
+	if len(payload.Tokens) == 0 {
+		return nil, fmt.Errorf("apns: no tokens supplied")
+	}
+
+	headers, body, err := p.buildRequest(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := nonEmptyTokens(payload.Tokens)
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("apns: tokens were empty")
+	}
+
+	return sendChunked(ctx, tokens, 1, p.concurrency, func(ctx context.Context, chunk []models.PushToken) ([]models.PushResult, error) {
+		res, err := p.sendOne(ctx, chunk[0].Token, headers, body)
+		if err != nil {
+			return nil, err
+		}
+		return []models.PushResult{res}, nil
+	})
+}
+
+// PayloadSize measures the JSON body against the APNs limit for its push type.
+func (p *APNsProvider) PayloadSize(payload *PushPayload) (int, int, error) {
+	headers, body, err := p.buildRequest(payload)
+	if err != nil {
+		return 0, 0, err
+	}
+	if headers.Get("apns-push-type") == "voip" {
+		return len(body), apnsMaxVoIPPayloadBytes, nil
+	}
+	return len(body), apnsMaxPayloadBytes, nil
+}
+
+// apnsHeaderOverrides maps provider_overrides["apns"] keys onto request headers;
+// every other override key is merged into the JSON body.
+var apnsHeaderOverrides = map[string]string{
+	"topic":       "apns-topic",
+	"push_type":   "apns-push-type",
+	"priority":    "apns-priority",
+	"expiration":  "apns-expiration",
+	"collapse_id": "apns-collapse-id",
+}
+
+func (p *APNsProvider) buildRequest(payload *PushPayload) (http.Header, []byte, error) {
+	headers := http.Header{}
+	headers.Set("apns-topic", p.topic)
+
+	var aps map[string]interface{}
+	switch {
+	case payload.Kind == KindVoIP:
+		headers.Set("apns-topic", apnsTopic(p.topic, ".voip"))
+		headers.Set("apns-push-type", "voip")
+		headers.Set("apns-priority", "10")
+		aps = map[string]interface{}{}
+	case payload.Kind == KindLiveActivity:
+		headers.Set("apns-topic", apnsTopic(p.topic, ".push-type.liveactivity"))
+		headers.Set("apns-push-type", "liveactivity")
+		headers.Set("apns-priority", "10")
+		aps = apnsLiveActivity(payload, time.Now())
+	case payload.Kind.IsAlert():
+		headers.Set("apns-push-type", "alert")
+		headers.Set("apns-priority", "10")
+		aps = apnsAlert(payload)
+	default:
+		// APNs has no data-only alert, so data messages are background pushes;
+		// Apple requires priority 5 for them.
+		headers.Set("apns-push-type", "background")
+		headers.Set("apns-priority", "5")
+		aps = map[string]interface{}{"content-available": 1}
+	}
+	if payload.TTL > 0 {
+		headers.Set("apns-expiration", strconv.FormatInt(time.Now().Add(payload.TTL).Unix(), 10))
+	}
+	if payload.CollapseKey != "" {
+		headers.Set("apns-collapse-id", payload.CollapseKey)
+	}
+	if payload.Priority != "" && payload.Kind != KindData && payload.Kind != KindBackground {
+		headers.Set("apns-priority", apnsPriority(payload.Priority))
+	}
+
+	body := map[string]interface{}{"aps": aps}
+	if payload.Kind.IsAlert() {
+		// APNs has no native image or link fields; the app reads these custom
+		// keys, the image from its notification service extension.
+		if payload.Notification.ImageURL != "" {
+			body["image_url"] = payload.Notification.ImageURL
+		}
+		if payload.Notification.ClickAction != "" {
+			body["click_action"] = payload.Notification.ClickAction
+		}
+	}
+	for key, value := range payload.Data {
+		if key == "aps" {
+			continue
+		}
+		body[key] = value
+	}
+
+	if overrides := providerOverrides(payload.Overrides, p.Name()); overrides != nil {
+		bodyOverrides := make(map[string]interface{}, len(overrides))
+		for key, value := range overrides {
+			if header, ok := apnsHeaderOverrides[key]; ok {
+				headers.Set(header, fmt.Sprint(value))
+				continue
+			}
+			bodyOverrides[key] = value
+		}
+		mergeMaps(body, bodyOverrides)
+	}
+
+	if headers.Get("apns-topic") == "" {
+		return nil, nil, fmt.Errorf("apns: topic is not configured")
+	}
+
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return headers, raw, nil
+}
+
+func apnsPriority(priority Priority) string {
+	if priority == PriorityNormal {
+		return "5"
+	}
+	return "10"
+}
+
+// apnsTopic appends the push type suffix Apple requires on VoIP and Live
+// Activity topics.
+func apnsTopic(topic, suffix string) string {
+	if topic == "" {
+		return ""
+	}
+	return topic + suffix
+}
+
+// apnsLiveActivity builds the aps dictionary of a Live Activity push. The
+// alert is optional and only shown when the message has a title or body.
+func apnsLiveActivity(payload *PushPayload, now time.Time) map[string]interface{} {
+	aps := map[string]interface{}{"timestamp": now.Unix()}
+	activity := payload.LiveActivity
+	if activity == nil {
+		return aps
+	}
+	aps["event"] = activity.Event
+	aps["content-state"] = activity.ContentState
+	if activity.StaleDate != nil {
+		aps["stale-date"] = activity.StaleDate.Unix()
+	}
+	if activity.DismissalDate != nil {
+		aps["dismissal-date"] = activity.DismissalDate.Unix()
+	}
+	if activity.Event == models.LiveActivityStart {
+		aps["attributes-type"] = activity.AttributesType
+		aps["attributes"] = activity.Attributes
+	}
+	if payload.Title != "" || payload.Body != "" {
+		aps["alert"] = map[string]interface{}{
+			"title": payload.Title,
+			"body":  payload.Body,
+		}
+		if payload.Notification.Sound != "" {
+			aps["sound"] = payload.Notification.Sound
+		}
+	}
+	return aps
+}
+
+// apnsAlert builds the aps dictionary for an alert. Action buttons on iOS come
+// from the category the app registered, so Actions are not sent.
+func apnsAlert(payload *PushPayload) map[string]interface{} {
+	opts := payload.Notification
+	aps := map[string]interface{}{
+		"alert": map[string]interface{}{
+			"title": payload.Title,
+			"body":  payload.Body,
+		},
+	}
+	if opts.Sound != "" {
+		aps["sound"] = opts.Sound
+	}
+	if opts.Badge != nil {
+		aps["badge"] = *opts.Badge
+	}
+	if opts.ThreadID != "" {
+		aps["thread-id"] = opts.ThreadID
+	}
+	if opts.Category != "" {
+		aps["category"] = opts.Category
+	}
+	if opts.ImageURL != "" {
+		aps["mutable-content"] = 1
+	}
+	return aps
+}
+
+func (p *APNsProvider) sendOne(ctx context.Context, token string, headers http.Header, body []byte) (models.PushResult, error) {
+	providerToken, err := p.providerToken()
+	if err != nil {
+		return models.PushResult{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.host+"/3/device/"+token, bytes.NewReader(body))
+	if err != nil {
+		return models.PushResult{}, err
+	}
+	for key, values := range headers {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "bearer "+providerToken)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return models.PushResult{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return models.PushResult{
+			Token:     token,
+			Provider:  p.Name(),
+			Status:    models.ResultDelivered,
+			MessageID: resp.Header.Get("apns-id"),
+		}, nil
+	}
+
+	var errResp struct {
+		Reason    string `json:"reason"`
+		Timestamp int64  `json:"timestamp"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&errResp)
+	if errResp.Reason == "" {
+		errResp.Reason = fmt.Sprintf("HTTP_%d", resp.StatusCode)
+	}
+
+	switch errResp.Reason {
+	case "ExpiredProviderToken", "InvalidProviderToken":
+		p.invalidateToken()
+		return models.PushResult{}, &StatusError{Provider: p.Name(), StatusCode: http.StatusForbidden, Detail: errResp.Reason}
+	}
+
+	result := models.PushResult{
+		Token:      token,
+		Provider:   p.Name(),
+		Status:     models.ResultFailed,
+		MessageID:  resp.Header.Get("apns-id"),
+		Error:      errResp.Reason,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+	if resp.StatusCode == http.StatusGone && errResp.Timestamp > 0 {
+		at := time.UnixMilli(errResp.Timestamp).UTC()
+		result.UnregisteredAt = &at
+	}
+	return result, nil
+}
+
+// providerToken returns the cached provider JWT, signing a new one before the
+// current token reaches Apple's one hour expiry.
+func (p *APNsProvider) providerToken() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	if p.jwt != "" && now.Sub(p.jwtIssued) < apnsTokenLifetime {
+		return p.jwt, nil
+	}
+
+	header := map[string]interface{}{
+		"alg": "ES256",
+		"kid": p.keyID,
+	}
+	claims := map[string]interface{}{
+		"iss": p.teamID,
+		"iat": now.Unix(),
+	}
+	token, err := signJWT(header, claims, es256Signer(p.key))
+	if err != nil {
+		return "", fmt.Errorf("apns: sign provider token: %w", err)
+	}
+	p.jwt = token
+	p.jwtIssued = now
+	return token, nil
+}
+
+func (p *APNsProvider) invalidateToken() {
+	p.mu.Lock()
+	p.jwt = ""
+	p.mu.Unlock()
+}
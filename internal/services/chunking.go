@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/CyberwizD/Distributed-Notification-System/services/push_service/internal/models"
+)
+
+const defaultSendConcurrency = 8
+
+// chunkSender sends one chunk of tokens and returns results in chunk order.
+type chunkSender func(ctx context.Context, chunk []models.PushToken) ([]models.PushResult, error)
+
+// sendChunked splits tokens into chunks of at most size, sends them with at
+// most workers requests in flight and reassembles the results in token order.
+// Results from successful chunks are returned alongside any chunk errors.
+func sendChunked(ctx context.Context, tokens []models.PushToken, size, workers int, send chunkSender) ([]models.PushResult, error) {
+	if size <= 0 {
+		size = len(tokens)
+	}
+	if workers <= 0 {
+		workers = defaultSendConcurrency
+	}
+
+	var chunks [][]models.PushToken
+	for start := 0; start < len(tokens); start += size {
+		end := start + size
+		if end > len(tokens) {
+			end = len(tokens)
+		}
+		chunks = append(chunks, tokens[start:end])
+	}
+	if len(chunks) == 1 {
+		return send(ctx, chunks[0])
+	}
+
+	type outcome struct {
+		results []models.PushResult
+		err     error
+	}
+	outcomes := make([]outcome, len(chunks))
+	jobs := make(chan int)
+
+	if workers > len(chunks) {
+		workers = len(chunks)
+	}
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results, err := send(ctx, chunks[i])
+				outcomes[i] = outcome{results: results, err: err}
+			}
+		}()
+	}
+	for i := range chunks {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	var (
+		results []models.PushResult
+		errs    []error
+	)
+	for _, o := range outcomes {
+		results = append(results, o.results...)
+		if o.err != nil {
+			errs = append(errs, o.err)
+		}
+	}
+	return results, errors.Join(errs...)
+}
+
+// nonEmptyTokens drops tokens without a value.
+func nonEmptyTokens(tokens []models.PushToken) []models.PushToken {
+	filtered := make([]models.PushToken, 0, len(tokens))
+	for _, token := range tokens {
+		if token.Token != "" {
+			filtered = append(filtered, token)
+		}
+	}
+	return filtered
+}
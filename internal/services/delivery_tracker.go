@@ -0,0 +1,104 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/CyberwizD/Distributed-Notification-System/services/push_service/internal/models"
+)
+
+// deliveryTracker records the latest outcome per token across retry attempts so
+// that retries only target tokens that have not been delivered yet.
+type deliveryTracker struct {
+	tokens  []models.PushToken
+	results map[string]models.PushResult
+}
+
+func newDeliveryTracker(tokens []models.PushToken) *deliveryTracker {
+	return &deliveryTracker{
+		tokens:  tokens,
+		results: make(map[string]models.PushResult, len(tokens)),
+	}
+}
+
+// Record stores the outcome of an attempt. A delivered token is never
+// overwritten by a later attempt.
+func (t *deliveryTracker) Record(results []models.PushResult) {
+	for _, res := range results {
+		if prev, ok := t.results[res.Token]; ok && prev.Status == models.ResultDelivered {
+			continue
+		}
+		t.results[res.Token] = res
+	}
+}
+
+// Pending returns tokens that still need sending: those without an outcome
+// and those whose last failure is retryable. Token- and message-fatal
+// failures are dropped immediately.
+func (t *deliveryTracker) Pending() []models.PushToken {
+	var pending []models.PushToken
+	for _, token := range t.tokens {
+		res, ok := t.results[token.Token]
+		if !ok {
+			pending = append(pending, token)
+			continue
+		}
+		if res.Status != models.ResultDelivered && ClassifyErrorCode(res.Error) == ErrorRetryable {
+			pending = append(pending, token)
+		}
+	}
+	return pending
+}
+
+// Results returns the recorded outcomes in token order.
+func (t *deliveryTracker) Results() []models.PushResult {
+	results := make([]models.PushResult, 0, len(t.results))
+	for _, token := range t.tokens {
+		if res, ok := t.results[token.Token]; ok {
+			results = append(results, res)
+		}
+	}
+	return results
+}
+
+// Summary counts delivered tokens and describes every token that was not
+// delivered, including tokens that never produced a result.
+func (t *deliveryTracker) Summary() (delivered int, failures []string) {
+	for _, token := range t.tokens {
+		res, ok := t.results[token.Token]
+		switch {
+		case !ok:
+			failures = append(failures, fmt.Sprintf("%s:no result", token.Token))
+		case res.Status == models.ResultDelivered:
+			delivered++
+		default:
+			failures = append(failures, fmt.Sprintf("%s:%s", res.Token, res.Error))
+		}
+	}
+	return delivered, failures
+}
+
+// PendingError reports the tokens still awaiting delivery along with the
+// longest Retry-After any of them was given.
+func (t *deliveryTracker) PendingError(pending []models.PushToken) error {
+	err := &pendingTokensError{tokens: make([]string, 0, len(pending))}
+	for _, token := range pending {
+		err.tokens = append(err.tokens, token.Token)
+		if res, ok := t.results[token.Token]; ok && res.RetryAfter > err.wait {
+			err.wait = res.RetryAfter
+		}
+	}
+	return err
+}
+
+type pendingTokensError struct {
+	tokens []string
+	wait   time.Duration
+}
+
+func (e *pendingTokensError) Error() string {
+	return fmt.Sprintf("%d tokens pending retry: %s", len(e.tokens), strings.Join(e.tokens, ", "))
+}
+
+func (e *pendingTokensError) RetryAfter() time.Duration { return e.wait }
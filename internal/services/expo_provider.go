@@ -0,0 +1,409 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CyberwizD/Distributed-Notification-System/services/push_service/internal/models"
+	"github.com/CyberwizD/Distributed-Notification-System/services/push_service/internal/repository"
+)
+
+const (
+	defaultExpoEndpoint = "https://exp.host/--/api/v2/push"
+	// expoMaxMessagesPerRequest is the Expo push API batch limit.
+	expoMaxMessagesPerRequest = 100
+	// expoMaxPayloadBytes is the Expo limit for a single message.
+	expoMaxPayloadBytes = 4096
+	// expoMaxReceiptsPerRequest is the getReceipts id limit.
+	expoMaxReceiptsPerRequest = 1000
+	// expoMaxPendingReceipts bounds the ticket backlog checked per poll, and
+	// the in-memory backlog used without Redis.
+	expoMaxPendingReceipts = 100000
+	// expoReceiptTTL is how long Expo keeps push receipts.
+	expoReceiptTTL = 24 * time.Hour
+)
+
+// ExpoConfig configures the Expo push provider.
+type ExpoConfig struct {
+	// Endpoint is the API base; /send and /getReceipts are appended.
+	Endpoint    string
+	AccessToken string
+	// ReceiptInterval is how often receipts are polled. Expo makes receipts
+	// available some time after the ticket, so tickets younger than one
+	// interval are left for the next poll.
+	ReceiptInterval time.Duration
+	Timeout         time.Duration
+	Concurrency     int
+}
+
+// ExpoProvider sends notifications to Expo push tokens and polls push
+// receipts in the background to find devices that are no longer registered.
+// Pending tickets are kept in Redis, when configured, so restarts do not lose
+// them; otherwise they are kept in memory.
+type ExpoProvider struct {
+	endpoint        string
+	accessToken     string
+	receiptInterval time.Duration
+	client          *http.Client
+	cache           *repository.RedisRepository
+	logger          *slog.Logger
+	concurrency     int
+
+	mu      sync.Mutex
+	tickets map[string]expoTicketRef
+}
+
+type expoTicketRef struct {
+	token  string
+	issued time.Time
+}
+
+func NewExpoProvider(cfg ExpoConfig, cache *repository.RedisRepository, logger *slog.Logger) *ExpoProvider {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = defaultExpoEndpoint
+	}
+	if cfg.ReceiptInterval <= 0 {
+		cfg.ReceiptInterval = 15 * time.Minute
+	}
+	return &ExpoProvider{
+		endpoint:        strings.TrimRight(cfg.Endpoint, "/"),
+		accessToken:     cfg.AccessToken,
+		receiptInterval: cfg.ReceiptInterval,
+		client: &http.Client{
+			Timeout: cfg.Timeout,
+		},
+		cache:       cache,
+		logger:      logger,
+		concurrency: cfg.Concurrency,
+		tickets:     make(map[string]expoTicketRef),
+	}
+}
+
+func (p *ExpoProvider) Name() string {
+	return "expo"
+}
+
+// Claims recognises ExponentPushToken[...] and ExpoPushToken[...] tokens.
+func (p *ExpoProvider) Claims(token models.PushToken) bool {
+	return isExpoToken(token.Token)
+}
+
+func isExpoToken(token string) bool {
+	return (strings.HasPrefix(token, "ExponentPushToken[") || strings.HasPrefix(token, "ExpoPushToken[")) &&
+		strings.HasSuffix(token, "]")
+}
+
+func (p *ExpoProvider) Send(ctx context.Context, payload *PushPayload) ([]models.PushResult, error) {
+	if len(payload.Tokens) == 0 {
+		return nil, fmt.Errorf("expo: no tokens supplied")
+	}
+
+	tokens := nonEmptyTokens(payload.Tokens)
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("expo: tokens were empty")
+	}
+
+	return sendChunked(ctx, tokens, expoMaxMessagesPerRequest, p.concurrency, func(ctx context.Context, chunk []models.PushToken) ([]models.PushResult, error) {
+		return p.sendChunk(ctx, chunk, payload)
+	})
+}
+
+func (p *ExpoProvider) sendChunk(ctx context.Context, chunk []models.PushToken, payload *PushPayload) ([]models.PushResult, error) {
+	messages := make([]map[string]interface{}, 0, len(chunk))
+	for _, token := range chunk {
+		msg := expoMessage(token.Token, payload)
+		messages = append(messages, msg)
+	}
+
+	var sendResp struct {
+		Data   []expoTicket `json:"data"`
+		Errors []expoError  `json:"errors"`
+	}
+	if err := p.post(ctx, "/send", messages, &sendResp); err != nil {
+		return nil, err
+	}
+	if len(sendResp.Errors) > 0 && len(sendResp.Data) == 0 {
+		return nil, fmt.Errorf("expo: %s: %s", sendResp.Errors[0].Code, sendResp.Errors[0].Message)
+	}
+
+	// Tickets are index-aligned with the messages of this chunk.
+	now := time.Now()
+	results := make([]models.PushResult, 0, len(sendResp.Data))
+	for idx, ticket := range sendResp.Data {
+		if idx >= len(chunk) {
+			break
+		}
+		token := chunk[idx].Token
+		res := models.PushResult{
+			Token:     token,
+			Provider:  p.Name(),
+			Status:    models.ResultDelivered,
+			MessageID: ticket.ID,
+		}
+		if ticket.Status != "ok" {
+			res.Status = models.ResultFailed
+			res.Error = ticket.errorCode()
+		} else if ticket.ID != "" {
+			p.trackTicket(ctx, ticket.ID, token, now)
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+func expoMessage(token string, payload *PushPayload) map[string]interface{} {
+	msg := map[string]interface{}{
+		"to": token,
+	}
+	switch payload.Kind {
+	case KindData:
+	case KindBackground:
+		msg["_contentAvailable"] = true
+		msg["priority"] = "normal"
+	default:
+		msg["title"] = payload.Title
+		msg["body"] = payload.Body
+		expoNotification(msg, payload.Notification)
+	}
+	if payload.TTL > 0 {
+		msg["ttl"] = int(payload.TTL / time.Second)
+	}
+	if payload.Priority != "" {
+		msg["priority"] = string(payload.Priority)
+	}
+	if data := expoData(payload); len(data) > 0 {
+		msg["data"] = data
+	}
+	if overrides := providerOverrides(payload.Overrides, "expo"); overrides != nil {
+		mergeMaps(msg, overrides)
+	}
+	return msg
+}
+
+// PayloadSize measures one message without its token against the Expo limit.
+func (p *ExpoProvider) PayloadSize(payload *PushPayload) (int, int, error) {
+	body, err := json.Marshal(expoMessage("", payload))
+	if err != nil {
+		return 0, 0, err
+	}
+	return len(body), expoMaxPayloadBytes, nil
+}
+
+func expoNotification(msg map[string]interface{}, opts models.NotificationOptions) {
+	if opts.ImageURL != "" {
+		msg["richContent"] = map[string]string{"image": opts.ImageURL}
+		msg["mutableContent"] = true
+	}
+	if opts.Sound != "" {
+		msg["sound"] = opts.Sound
+	}
+	if opts.Badge != nil {
+		msg["badge"] = *opts.Badge
+	}
+	if opts.ChannelID != "" {
+		msg["channelId"] = opts.ChannelID
+	}
+	if opts.Category != "" {
+		msg["categoryId"] = opts.Category
+	}
+}
+
+// expoData adds the deep link as data.url, the key expo-router and the Expo
+// linking examples open on tap.
+func expoData(payload *PushPayload) map[string]string {
+	link := payload.Notification.ClickAction
+	if !payload.Kind.IsAlert() || link == "" {
+		return payload.Data
+	}
+	data := make(map[string]string, len(payload.Data)+1)
+	for key, value := range payload.Data {
+		data[key] = value
+	}
+	data["url"] = link
+	return data
+}
+
+func (p *ExpoProvider) trackTicket(ctx context.Context, id, token string, issued time.Time) {
+	if p.cache != nil {
+		err := p.cache.TrackExpoTicket(ctx, id, token, issued, expoReceiptTTL)
+		if err == nil {
+			return
+		}
+		p.logger.Warn("failed to store expo ticket, keeping it in memory", slog.Any("error", err))
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.tickets) >= expoMaxPendingReceipts {
+		p.logger.Warn("expo receipt backlog full, dropping ticket", slog.String("ticket", id))
+		return
+	}
+	p.tickets[id] = expoTicketRef{token: token, issued: issued}
+}
+
+// Run polls push receipts every ReceiptInterval until ctx is cancelled.
+func (p *ExpoProvider) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.receiptInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.pollReceipts(ctx)
+		}
+	}
+}
+
+// pollReceipts fetches receipts for tickets old enough to have one and
+// suppresses tokens Expo reports as DeviceNotRegistered.
+func (p *ExpoProvider) pollReceipts(ctx context.Context) {
+	due := p.dueTickets(ctx, time.Now().Add(-p.receiptInterval/2))
+	ids := make([]string, 0, len(due))
+	for id := range due {
+		ids = append(ids, id)
+	}
+
+	for start := 0; start < len(ids); start += expoMaxReceiptsPerRequest {
+		end := start + expoMaxReceiptsPerRequest
+		if end > len(ids) {
+			end = len(ids)
+		}
+		if err := p.checkReceipts(ctx, ids[start:end], due); err != nil {
+			p.logger.Warn("expo receipt poll failed", slog.Any("error", err))
+			return
+		}
+	}
+}
+
+// dueTickets returns the tickets issued before cutoff, as ticket id to token,
+// from memory and Redis. Tickets older than Expo keeps receipts are dropped.
+func (p *ExpoProvider) dueTickets(ctx context.Context, cutoff time.Time) map[string]string {
+	due := make(map[string]string)
+	p.mu.Lock()
+	for id, ref := range p.tickets {
+		switch {
+		case time.Since(ref.issued) > expoReceiptTTL:
+			delete(p.tickets, id)
+		case ref.issued.Before(cutoff):
+			due[id] = ref.token
+		}
+	}
+	p.mu.Unlock()
+
+	if p.cache != nil {
+		stored, err := p.cache.DueExpoTickets(ctx, cutoff, expoMaxPendingReceipts)
+		if err != nil {
+			p.logger.Warn("failed to load expo tickets", slog.Any("error", err))
+		}
+		for id, token := range stored {
+			due[id] = token
+		}
+	}
+	return due
+}
+
+// checkReceipts fetches the receipts for ids, whose tokens are in tokens, and
+// stops tracking the tickets that have one.
+func (p *ExpoProvider) checkReceipts(ctx context.Context, ids []string, tokens map[string]string) error {
+	var receiptResp struct {
+		Data map[string]expoTicket `json:"data"`
+	}
+	if err := p.post(ctx, "/getReceipts", map[string]interface{}{"ids": ids}, &receiptResp); err != nil {
+		return err
+	}
+
+	ready := make([]string, 0, len(receiptResp.Data))
+	p.mu.Lock()
+	for _, id := range ids {
+		if _, ok := receiptResp.Data[id]; ok {
+			delete(p.tickets, id)
+			ready = append(ready, id)
+		}
+	}
+	p.mu.Unlock()
+	if p.cache != nil {
+		if err := p.cache.ForgetExpoTickets(ctx, ready...); err != nil {
+			p.logger.Warn("failed to remove checked expo tickets", slog.Any("error", err))
+		}
+	}
+
+	for _, id := range ready {
+		receipt := receiptResp.Data[id]
+		if receipt.Status == "ok" {
+			continue
+		}
+		code := receipt.errorCode()
+		p.logger.Info("expo receipt reported failure", slog.String("ticket", id), slog.String("error", code))
+		if code == "DeviceNotRegistered" && p.cache != nil {
+			if err := p.cache.SuppressToken(ctx, tokens[id], 0); err != nil {
+				p.logger.Warn("failed to suppress expo token", slog.Any("error", err))
+			}
+		}
+	}
+	return nil
+}
+
+func (p *ExpoProvider) post(ctx context.Context, path string, payload interface{}, out interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if p.accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+p.accessToken)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return &StatusError{
+			Provider:   p.Name(),
+			StatusCode: resp.StatusCode,
+			Wait:       parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		}
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// expoTicket is both a push ticket and a push receipt; they share a shape.
+type expoTicket struct {
+	Status  string `json:"status"`
+	ID      string `json:"id"`
+	Message string `json:"message"`
+	Details struct {
+		Error string `json:"error"`
+	} `json:"details"`
+}
+
+func (t expoTicket) errorCode() string {
+	if t.Details.Error != "" {
+		return t.Details.Error
+	}
+	return "ExpoError"
+}
+
+type expoError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	"log/slog"
@@ -13,27 +14,39 @@ import (
 	"github.com/CyberwizD/Distributed-Notification-System/services/push_service/internal/models"
 )
 
+const (
+	// fcmMaxTokensPerRequest is the legacy API limit for registration_ids.
+	fcmMaxTokensPerRequest = 1000
+	// fcmMaxPayloadBytes is the FCM limit for a message payload.
+	fcmMaxPayloadBytes = 4096
+)
+
 // FCMProvider sends notifications via Firebase Cloud Messaging.
 type FCMProvider struct {
-	serverKey string
-	endpoint  string
-	client    *http.Client
-	logger    *slog.Logger
-	timeout   time.Duration
+	serverKey   string
+	endpoint    string
+	client      *http.Client
+	logger      *slog.Logger
+	timeout     time.Duration
+	concurrency int
 }
 
-func NewFCMProvider(serverKey, endpoint string, timeout time.Duration, logger *slog.Logger) *FCMProvider {
+func NewFCMProvider(serverKey, endpoint string, timeout time.Duration, concurrency int, logger *slog.Logger) *FCMProvider {
 	if timeout <= 0 {
 		timeout = 10 * time.Second
 	}
+	if concurrency <= 0 {
+		concurrency = defaultSendConcurrency
+	}
 	return &FCMProvider{
 		serverKey: serverKey,
 		endpoint:  endpoint,
 		client: &http.Client{
 			Timeout: timeout,
 		},
-		logger:  logger,
-		timeout: timeout,
+		logger:      logger,
+		timeout:     timeout,
+		concurrency: concurrency,
 	}
 }
 
@@ -41,38 +54,33 @@ func (p *FCMProvider) Name() string {
 	return "fcm"
 }
 
+// SupportsDryRun reports that FCM validates dry_run requests without delivering them.
+func (p *FCMProvider) SupportsDryRun() bool {
+	return true
+}
+
 func (p *FCMProvider) Send(ctx context.Context, payload *PushPayload) ([]models.PushResult, error) {
 	if len(payload.Tokens) == 0 {
 		return nil, fmt.Errorf("fcm: no tokens supplied")
 	}
 
-	regIDs := make([]string, 0, len(payload.Tokens))
-	for _, token := range payload.Tokens {
-		if token.Token == "" {
-			continue
-		}
-		regIDs = append(regIDs, token.Token)
-	}
-
-	if len(regIDs) == 0 {
+	tokens := nonEmptyTokens(payload.Tokens)
+	if len(tokens) == 0 {
 		return nil, fmt.Errorf("fcm: tokens were empty")
 	}
 
-	reqMap := map[string]interface{}{
-		"registration_ids": regIDs,
-		"notification": map[string]string{
-			"title": payload.Title,
-			"body":  payload.Body,
-		},
-	}
-	if len(payload.Data) > 0 {
-		reqMap["data"] = payload.Data
-	}
-	if overrides := providerOverrides(payload.Overrides, "fcm"); overrides != nil {
-		mergeMaps(reqMap, overrides)
+	return sendChunked(ctx, tokens, fcmMaxTokensPerRequest, p.concurrency, func(ctx context.Context, chunk []models.PushToken) ([]models.PushResult, error) {
+		return p.sendChunk(ctx, chunk, payload)
+	})
+}
+
+func (p *FCMProvider) sendChunk(ctx context.Context, chunk []models.PushToken, payload *PushPayload) ([]models.PushResult, error) {
+	regIDs := make([]string, 0, len(chunk))
+	for _, token := range chunk {
+		regIDs = append(regIDs, token.Token)
 	}
 
-	body, err := json.Marshal(reqMap)
+	body, err := p.buildRequest(regIDs, payload)
 	if err != nil {
 		return nil, err
 	}
@@ -91,7 +99,11 @@ func (p *FCMProvider) Send(ctx context.Context, payload *PushPayload) ([]models.
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
-		return nil, fmt.Errorf("fcm: received status %d", resp.StatusCode)
+		return nil, &StatusError{
+			Provider:   p.Name(),
+			StatusCode: resp.StatusCode,
+			Wait:       parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		}
 	}
 
 	var fcmResp fcmResponse
@@ -99,24 +111,30 @@ func (p *FCMProvider) Send(ctx context.Context, payload *PushPayload) ([]models.
 		return nil, err
 	}
 
+	// Results are index-aligned with registration_ids of this chunk.
+	retryAfter := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
 	results := make([]models.PushResult, 0, len(fcmResp.Results))
 	for idx, res := range fcmResp.Results {
 		token := ""
 		if idx < len(regIDs) {
 			token = regIDs[idx]
 		}
-		status := models.ResultDelivered
-		if res.Error != "" {
-			status = models.ResultFailed
-		}
-
-		results = append(results, models.PushResult{
+		result := models.PushResult{
 			Token:     token,
 			Provider:  p.Name(),
-			Status:    status,
+			Status:    models.ResultDelivered,
 			MessageID: res.MessageID,
 			Error:     res.Error,
-		})
+		}
+		if res.RegistrationID != "" && res.RegistrationID != token {
+			result.CanonicalToken = res.RegistrationID
+		}
+		if res.Error != "" {
+			result.Status = models.ResultFailed
+			result.RetryAfter = retryAfter
+		}
+
+		results = append(results, result)
 	}
 
 	return results, nil
@@ -126,11 +144,115 @@ type fcmResponse struct {
 	Success int `json:"success"`
 	Failure int `json:"failure"`
 	Results []struct {
-		MessageID string `json:"message_id"`
-		Error     string `json:"error"`
+		MessageID      string `json:"message_id"`
+		RegistrationID string `json:"registration_id"`
+		Error          string `json:"error"`
 	} `json:"results"`
 }
 
+// buildRequest serializes the legacy request for regIDs.
+func (p *FCMProvider) buildRequest(regIDs []string, payload *PushPayload) ([]byte, error) {
+	reqMap := map[string]interface{}{
+		"registration_ids": regIDs,
+	}
+	switch payload.Kind {
+	case KindData:
+	case KindBackground:
+		reqMap["content_available"] = true
+		reqMap["priority"] = "normal"
+	default:
+		reqMap["notification"] = fcmLegacyNotification(payload)
+	}
+	if payload.TTL > 0 {
+		reqMap["time_to_live"] = fcmTTLSeconds(payload.TTL)
+	}
+	if payload.CollapseKey != "" {
+		reqMap["collapse_key"] = payload.CollapseKey
+	}
+	if payload.Priority != "" {
+		reqMap["priority"] = string(payload.Priority)
+	}
+	data, err := fcmData(payload)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > 0 {
+		reqMap["data"] = data
+	}
+	if payload.DryRun {
+		reqMap["dry_run"] = true
+	}
+	if overrides := providerOverrides(payload.Overrides, "fcm"); overrides != nil {
+		mergeMaps(reqMap, overrides)
+	}
+
+	return json.Marshal(reqMap)
+}
+
+// PayloadSize measures the request without registration IDs against the 4KB
+// FCM message limit.
+func (p *FCMProvider) PayloadSize(payload *PushPayload) (int, int, error) {
+	body, err := p.buildRequest(nil, payload)
+	if err != nil {
+		return 0, 0, err
+	}
+	return len(body), fcmMaxPayloadBytes, nil
+}
+
+// fcmMaxTTL is the longest time FCM stores a message for an offline device.
+const fcmMaxTTL = 28 * 24 * time.Hour
+
+func fcmTTLSeconds(ttl time.Duration) int {
+	if ttl > fcmMaxTTL {
+		ttl = fcmMaxTTL
+	}
+	return int(ttl / time.Second)
+}
+
+// fcmLegacyNotification builds the legacy notification block, which carries
+// the Android and iOS presentation fields side by side.
+func fcmLegacyNotification(payload *PushPayload) map[string]interface{} {
+	opts := payload.Notification
+	notification := map[string]interface{}{
+		"title": payload.Title,
+		"body":  payload.Body,
+	}
+	if opts.ImageURL != "" {
+		notification["image"] = opts.ImageURL
+	}
+	if opts.ClickAction != "" {
+		notification["click_action"] = opts.ClickAction
+	}
+	if opts.Sound != "" {
+		notification["sound"] = opts.Sound
+	}
+	if opts.Badge != nil {
+		notification["badge"] = strconv.Itoa(*opts.Badge)
+	}
+	if opts.ChannelID != "" {
+		notification["android_channel_id"] = opts.ChannelID
+	}
+	return notification
+}
+
+// fcmData returns the data block. FCM has no native action buttons, so alert
+// actions travel as a JSON encoded "actions" entry for the app to render.
+func fcmData(payload *PushPayload) (map[string]string, error) {
+	if !payload.Kind.IsAlert() || len(payload.Notification.Actions) == 0 {
+		return payload.Data, nil
+	}
+	actions, err := json.Marshal(payload.Notification.Actions)
+	if err != nil {
+		return nil, err
+	}
+	data := make(map[string]string, len(payload.Data)+1)
+	for key, value := range payload.Data {
+		data[key] = value
+	}
+	data["actions"] = string(actions)
+	return data, nil
+}
+
 func providerOverrides(overrides map[string]interface{}, key string) map[string]interface{} {
 	if overrides == nil {
 		return nil
@@ -0,0 +1,377 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CyberwizD/Distributed-Notification-System/services/push_service/internal/models"
+)
+
+const defaultFCMV1Endpoint = "https://fcm.googleapis.com/v1"
+
+// FCMV1Config configures the FCM HTTP v1 provider.
+type FCMV1Config struct {
+	CredentialsFile string
+	ProjectID       string
+	Endpoint        string
+	TokenURL        string
+	Timeout         time.Duration
+	// Concurrency bounds the number of in-flight per-token requests.
+	Concurrency int
+}
+
+// FCMV1Provider sends notifications via the FCM HTTP v1 API using
+// service-account OAuth2 credentials. v1 accepts one token per request.
+type FCMV1Provider struct {
+	projectID   string
+	endpoint    string
+	tokens      *serviceAccountTokenSource
+	client      *http.Client
+	logger      *slog.Logger
+	concurrency int
+}
+
+func NewFCMV1Provider(cfg FCMV1Config, logger *slog.Logger) (*FCMV1Provider, error) {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = defaultFCMV1Endpoint
+	}
+
+	sa, err := loadServiceAccount(cfg.CredentialsFile)
+	if err != nil {
+		return nil, fmt.Errorf("fcm-v1: %w", err)
+	}
+	projectID := cfg.ProjectID
+	if projectID == "" {
+		projectID = sa.ProjectID
+	}
+	if projectID == "" {
+		return nil, fmt.Errorf("fcm-v1: project id is not configured")
+	}
+
+	client := &http.Client{Timeout: cfg.Timeout}
+	tokens, err := newServiceAccountTokenSource(sa, cfg.TokenURL, fcmMessagingScope, client)
+	if err != nil {
+		return nil, fmt.Errorf("fcm-v1: %w", err)
+	}
+
+	return &FCMV1Provider{
+		projectID:   projectID,
+		endpoint:    strings.TrimRight(cfg.Endpoint, "/"),
+		tokens:      tokens,
+		client:      client,
+		logger:      logger,
+		concurrency: cfg.Concurrency,
+	}, nil
+}
+
+func (p *FCMV1Provider) Name() string {
+	return "fcm-v1"
+}
+
+// SupportsDryRun reports that FCM v1 honours validate_only.
+func (p *FCMV1Provider) SupportsDryRun() bool {
+	return true
+}
+
+func (p *FCMV1Provider) Send(ctx context.Context, payload *PushPayload) ([]models.PushResult, error) {
+	if len(payload.Tokens) == 0 {
+		return nil, fmt.Errorf("fcm-v1: no tokens supplied")
+	}
+
+	tokens := nonEmptyTokens(payload.Tokens)
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("fcm-v1: tokens were empty")
+	}
+	if providerOverrides(payload.Overrides, "fcm") != nil && providerOverrides(payload.Overrides, p.Name()) == nil {
+		// Legacy request fields do not map onto the v1 message.
+		p.logger.Warn("ignoring provider_overrides[\"fcm\"] for fcm-v1, use provider_overrides[\"fcm-v1\"]")
+	}
+
+	return sendChunked(ctx, tokens, 1, p.concurrency, func(ctx context.Context, chunk []models.PushToken) ([]models.PushResult, error) {
+		res, err := p.sendOne(ctx, chunk[0].Token, payload)
+		if err != nil {
+			return nil, err
+		}
+		return []models.PushResult{res}, nil
+	})
+}
+
+// sendOne posts a single message. Per-token rejections are returned as a failed
+// PushResult; only transport and authentication problems are returned as errors.
+func (p *FCMV1Provider) sendOne(ctx context.Context, token string, payload *PushPayload) (models.PushResult, error) {
+	body, err := p.buildRequest(token, payload)
+	if err != nil {
+		return models.PushResult{}, err
+	}
+
+	accessToken, err := p.tokens.Token(ctx)
+	if err != nil {
+		return models.PushResult{}, fmt.Errorf("fcm-v1: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/projects/%s/messages:send", p.endpoint, p.projectID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return models.PushResult{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return models.PushResult{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		var ok struct {
+			Name string `json:"name"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&ok); err != nil {
+			return models.PushResult{}, err
+		}
+		return models.PushResult{
+			Token:     token,
+			Provider:  p.Name(),
+			Status:    models.ResultDelivered,
+			MessageID: ok.Name,
+		}, nil
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		p.tokens.Invalidate()
+		return models.PushResult{}, &StatusError{Provider: p.Name(), StatusCode: resp.StatusCode}
+	}
+
+	var errResp fcmV1ErrorResponse
+	_ = json.NewDecoder(resp.Body).Decode(&errResp)
+	code := errResp.code()
+	if code == "" {
+		code = fmt.Sprintf("HTTP_%d", resp.StatusCode)
+	}
+	if code == "INVALID_ARGUMENT" && !errResp.invalidToken() {
+		// The message itself is invalid and would be rejected for every token.
+		return models.PushResult{}, &StatusError{Provider: p.Name(), StatusCode: resp.StatusCode, Detail: errResp.Error.Message}
+	}
+	p.logger.Debug("fcm-v1 token rejected", slog.String("code", code), slog.String("message", errResp.Error.Message))
+
+	return models.PushResult{
+		Token:      token,
+		Provider:   p.Name(),
+		Status:     models.ResultFailed,
+		Error:      code,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}, nil
+}
+
+// buildRequest serializes the messages:send request for token.
+func (p *FCMV1Provider) buildRequest(token string, payload *PushPayload) ([]byte, error) {
+	message := map[string]interface{}{
+		"token": token,
+	}
+	switch payload.Kind {
+	case KindData:
+	case KindBackground:
+		message["android"] = map[string]interface{}{"priority": "NORMAL"}
+		message["apns"] = map[string]interface{}{
+			"headers": map[string]interface{}{
+				"apns-push-type": "background",
+				"apns-priority":  "5",
+			},
+			"payload": map[string]interface{}{
+				"aps": map[string]interface{}{"content-available": 1},
+			},
+		}
+	default:
+		fcmV1Notification(message, payload)
+	}
+	fcmV1DeliveryOptions(message, payload)
+	data, err := fcmData(payload)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > 0 {
+		message["data"] = data
+	}
+	if overrides := providerOverrides(payload.Overrides, p.Name()); overrides != nil {
+		mergeMaps(message, overrides)
+	}
+
+	request := map[string]interface{}{"message": message}
+	if payload.DryRun {
+		request["validate_only"] = true
+	}
+	return json.Marshal(request)
+}
+
+// PayloadSize measures the request without a token against the 4KB FCM
+// message limit.
+func (p *FCMV1Provider) PayloadSize(payload *PushPayload) (int, int, error) {
+	body, err := p.buildRequest("", payload)
+	if err != nil {
+		return 0, 0, err
+	}
+	return len(body), fcmMaxPayloadBytes, nil
+}
+
+// fcmV1Notification adds the notification block and the per-platform
+// presentation fields to message.
+func fcmV1Notification(message map[string]interface{}, payload *PushPayload) {
+	opts := payload.Notification
+	notification := map[string]interface{}{
+		"title": payload.Title,
+		"body":  payload.Body,
+	}
+	if opts.ImageURL != "" {
+		notification["image"] = opts.ImageURL
+	}
+	message["notification"] = notification
+
+	android := map[string]interface{}{}
+	if opts.ClickAction != "" {
+		android["click_action"] = opts.ClickAction
+	}
+	if opts.Sound != "" {
+		android["sound"] = opts.Sound
+	}
+	if opts.ChannelID != "" {
+		android["channel_id"] = opts.ChannelID
+	}
+	if opts.ThreadID != "" {
+		android["tag"] = opts.ThreadID
+	}
+	if opts.Badge != nil {
+		android["notification_count"] = *opts.Badge
+	}
+	if len(android) > 0 {
+		message["android"] = map[string]interface{}{"notification": android}
+	}
+
+	aps := map[string]interface{}{}
+	if opts.Sound != "" {
+		aps["sound"] = opts.Sound
+	}
+	if opts.Badge != nil {
+		aps["badge"] = *opts.Badge
+	}
+	if opts.ThreadID != "" {
+		aps["thread-id"] = opts.ThreadID
+	}
+	if opts.Category != "" {
+		aps["category"] = opts.Category
+	}
+	if opts.ImageURL != "" {
+		// Lets the app's notification service extension attach the image.
+		aps["mutable-content"] = 1
+	}
+	apns := map[string]interface{}{}
+	if len(aps) > 0 {
+		apns["payload"] = map[string]interface{}{"aps": aps}
+	}
+	if opts.ImageURL != "" {
+		apns["fcm_options"] = map[string]interface{}{"image": opts.ImageURL}
+	}
+	if len(apns) > 0 {
+		message["apns"] = apns
+	}
+
+	// FCM only accepts HTTPS links for web notifications.
+	if strings.HasPrefix(opts.ClickAction, "https://") {
+		message["webpush"] = map[string]interface{}{
+			"fcm_options": map[string]interface{}{"link": opts.ClickAction},
+		}
+	}
+}
+
+// fcmV1DeliveryOptions maps TTL, collapse key and priority onto each
+// platform block, since v1 has no cross-platform fields for them.
+func fcmV1DeliveryOptions(message map[string]interface{}, payload *PushPayload) {
+	android := map[string]interface{}{}
+	apnsHeaders := map[string]interface{}{}
+	webHeaders := map[string]interface{}{}
+
+	if payload.TTL > 0 {
+		ttl := fcmTTLSeconds(payload.TTL)
+		android["ttl"] = fmt.Sprintf("%ds", ttl)
+		apnsHeaders["apns-expiration"] = strconv.FormatInt(time.Now().Add(time.Duration(ttl)*time.Second).Unix(), 10)
+		webHeaders["TTL"] = strconv.Itoa(ttl)
+	}
+	if payload.CollapseKey != "" {
+		android["collapse_key"] = payload.CollapseKey
+		apnsHeaders["apns-collapse-id"] = payload.CollapseKey
+		webHeaders["Topic"] = webPushTopic(payload.CollapseKey)
+	}
+	switch payload.Priority {
+	case PriorityHigh:
+		android["priority"] = "HIGH"
+		webHeaders["Urgency"] = "high"
+	case PriorityNormal:
+		android["priority"] = "NORMAL"
+		webHeaders["Urgency"] = "normal"
+	}
+	// Background pushes must keep APNs priority 5.
+	if payload.Priority != "" && payload.Kind.IsAlert() {
+		apnsHeaders["apns-priority"] = apnsPriority(payload.Priority)
+	}
+
+	options := map[string]interface{}{}
+	if len(android) > 0 {
+		options["android"] = android
+	}
+	if len(apnsHeaders) > 0 {
+		options["apns"] = map[string]interface{}{"headers": apnsHeaders}
+	}
+	if len(webHeaders) > 0 {
+		options["webpush"] = map[string]interface{}{"headers": webHeaders}
+	}
+	mergeMaps(message, options)
+}
+
+type fcmV1ErrorResponse struct {
+	Error struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Status  string `json:"status"`
+		Details []struct {
+			Type            string `json:"@type"`
+			ErrorCode       string `json:"errorCode"`
+			FieldViolations []struct {
+				Field string `json:"field"`
+			} `json:"fieldViolations"`
+		} `json:"details"`
+	} `json:"error"`
+}
+
+// code prefers the FCM specific error code (UNREGISTERED, QUOTA_EXCEEDED, ...)
+// and falls back to the canonical Google API status.
+func (r fcmV1ErrorResponse) code() string {
+	for _, detail := range r.Error.Details {
+		if detail.ErrorCode != "" {
+			return detail.ErrorCode
+		}
+	}
+	return r.Error.Status
+}
+
+// invalidToken reports whether an INVALID_ARGUMENT error is about the
+// registration token rather than the message.
+func (r fcmV1ErrorResponse) invalidToken() bool {
+	for _, detail := range r.Error.Details {
+		for _, violation := range detail.FieldViolations {
+			if violation.Field == "message.token" {
+				return true
+			}
+		}
+	}
+	return strings.Contains(r.Error.Message, "registration token")
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+const (
+	fcmMessagingScope     = "https://www.googleapis.com/auth/firebase.messaging"
+	defaultGoogleTokenURL = "https://oauth2.googleapis.com/token"
+)
+
+// serviceAccountKey mirrors the fields we need from a Google service-account JSON file.
+type serviceAccountKey struct {
+	Type         string `json:"type"`
+	ProjectID    string `json:"project_id"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	ClientEmail  string `json:"client_email"`
+	TokenURI     string `json:"token_uri"`
+}
+
+// loadServiceAccount reads and validates a service-account JSON file.
+func loadServiceAccount(path string) (*serviceAccountKey, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read service account: %w", err)
+	}
+	var key serviceAccountKey
+	if err := json.Unmarshal(raw, &key); err != nil {
+		return nil, fmt.Errorf("decode service account: %w", err)
+	}
+	if key.ClientEmail == "" || key.PrivateKey == "" {
+		return nil, fmt.Errorf("service account is missing client_email or private_key")
+	}
+	return &key, nil
+}
+
+// serviceAccountTokenSource mints OAuth2 access tokens using the JWT bearer
+// grant and caches them until shortly before they expire.
+type serviceAccountTokenSource struct {
+	email    string
+	keyID    string
+	key      *rsa.PrivateKey
+	tokenURL string
+	scope    string
+	client   *http.Client
+	cache    accessTokenCache
+}
+
+func newServiceAccountTokenSource(sa *serviceAccountKey, tokenURL, scope string, client *http.Client) (*serviceAccountTokenSource, error) {
+	key, err := parseRSAPrivateKey([]byte(sa.PrivateKey))
+	if err != nil {
+		return nil, err
+	}
+	if tokenURL == "" {
+		tokenURL = sa.TokenURI
+	}
+	if tokenURL == "" {
+		tokenURL = defaultGoogleTokenURL
+	}
+	return &serviceAccountTokenSource{
+		email:    sa.ClientEmail,
+		keyID:    sa.PrivateKeyID,
+		key:      key,
+		tokenURL: tokenURL,
+		scope:    scope,
+		client:   client,
+	}, nil
+}
+
+// Token returns a cached access token, minting a new one when needed.
+func (s *serviceAccountTokenSource) Token(ctx context.Context) (string, error) {
+	return s.cache.Get(ctx, func(ctx context.Context) (string, time.Duration, error) {
+		assertion, err := s.assertion(time.Now())
+		if err != nil {
+			return "", 0, err
+		}
+		form := url.Values{}
+		form.Set("grant_type", "urn:ietf:params:oauth:grant-type:jwt-bearer")
+		form.Set("assertion", assertion)
+		return requestAccessToken(ctx, s.client, s.tokenURL, form)
+	})
+}
+
+// Invalidate drops the cached token so the next call mints a fresh one.
+func (s *serviceAccountTokenSource) Invalidate() {
+	s.cache.Invalidate()
+}
+
+func (s *serviceAccountTokenSource) assertion(now time.Time) (string, error) {
+	header := map[string]interface{}{
+		"alg": "RS256",
+		"typ": "JWT",
+	}
+	if s.keyID != "" {
+		header["kid"] = s.keyID
+	}
+	claims := map[string]interface{}{
+		"iss":   s.email,
+		"scope": s.scope,
+		"aud":   s.tokenURL,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}
+	return signJWT(header, claims, rs256Signer(s.key))
+}
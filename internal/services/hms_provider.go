@@ -0,0 +1,336 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/CyberwizD/Distributed-Notification-System/services/push_service/internal/models"
+)
+
+const (
+	defaultHMSTokenURL = "https://oauth-login.cloud.huawei.com/oauth2/v3/token"
+	defaultHMSEndpoint = "https://push-api.cloud.huawei.com/v1"
+	// hmsMaxTokensPerRequest is the Push Kit limit for message.token.
+	hmsMaxTokensPerRequest = 1000
+	// hmsMaxPayloadBytes is the Push Kit limit for a message.
+	hmsMaxPayloadBytes = 4096
+)
+
+// HMS Push Kit result codes.
+const (
+	hmsSuccess          = "80000000"
+	hmsPartialSuccess   = "80100000"
+	hmsInvalidToken     = "80300007"
+	hmsOAuthFailed      = "80200001"
+	hmsOAuthExpired     = "80200003"
+	hmsInternalError    = "81000001"
+	hmsMessageTooBig    = "80300008"
+	hmsInvalidParameter = "80100001"
+	hmsInvalidMessage   = "80100003"
+)
+
+// HMSConfig configures the Huawei Push Kit provider.
+type HMSConfig struct {
+	AppID        string
+	ClientID     string
+	ClientSecret string
+	TokenURL     string
+	Endpoint     string
+	Timeout      time.Duration
+	Concurrency  int
+}
+
+// HMSProvider sends notifications via Huawei Push Kit for devices without
+// Google Play services.
+type HMSProvider struct {
+	appID        string
+	clientID     string
+	clientSecret string
+	tokenURL     string
+	endpoint     string
+	client       *http.Client
+	logger       *slog.Logger
+	concurrency  int
+	tokens       accessTokenCache
+}
+
+func NewHMSProvider(cfg HMSConfig, logger *slog.Logger) *HMSProvider {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.ClientID == "" {
+		cfg.ClientID = cfg.AppID
+	}
+	if cfg.TokenURL == "" {
+		cfg.TokenURL = defaultHMSTokenURL
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = defaultHMSEndpoint
+	}
+	return &HMSProvider{
+		appID:        cfg.AppID,
+		clientID:     cfg.ClientID,
+		clientSecret: cfg.ClientSecret,
+		tokenURL:     cfg.TokenURL,
+		endpoint:     strings.TrimRight(cfg.Endpoint, "/"),
+		client: &http.Client{
+			Timeout: cfg.Timeout,
+		},
+		logger:      logger,
+		concurrency: cfg.Concurrency,
+	}
+}
+
+func (p *HMSProvider) Name() string {
+	return "hms"
+}
+
+// SupportsDryRun reports that Push Kit honours validate_only.
+func (p *HMSProvider) SupportsDryRun() bool {
+	return true
+}
+
+func (p *HMSProvider) Send(ctx context.Context, payload *PushPayload) ([]models.PushResult, error) {
+	if len(payload.Tokens) == 0 {
+		return nil, fmt.Errorf("hms: no tokens supplied")
+	}
+
+	tokens := nonEmptyTokens(payload.Tokens)
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("hms: tokens were empty")
+	}
+
+	return sendChunked(ctx, tokens, hmsMaxTokensPerRequest, p.concurrency, func(ctx context.Context, chunk []models.PushToken) ([]models.PushResult, error) {
+		return p.sendChunk(ctx, chunk, payload)
+	})
+}
+
+func (p *HMSProvider) sendChunk(ctx context.Context, chunk []models.PushToken, payload *PushPayload) ([]models.PushResult, error) {
+	tokens := make([]string, 0, len(chunk))
+	for _, token := range chunk {
+		tokens = append(tokens, token.Token)
+	}
+
+	body, err := p.buildRequest(tokens, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, err := p.accessToken(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("hms: %w", err)
+	}
+
+	endpoint := fmt.Sprintf("%s/%s/messages:send", p.endpoint, url.PathEscape(p.appID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var hmsResp hmsResponse
+	decodeErr := json.NewDecoder(resp.Body).Decode(&hmsResp)
+
+	if resp.StatusCode == http.StatusUnauthorized || hmsResp.Code == hmsOAuthFailed || hmsResp.Code == hmsOAuthExpired {
+		p.tokens.Invalidate()
+		return nil, &StatusError{Provider: p.Name(), StatusCode: http.StatusUnauthorized, Detail: hmsResp.Msg}
+	}
+	if decodeErr != nil || hmsResp.Code == "" {
+		if resp.StatusCode >= 400 {
+			return nil, &StatusError{
+				Provider:   p.Name(),
+				StatusCode: resp.StatusCode,
+				Wait:       parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+			}
+		}
+		if decodeErr != nil {
+			return nil, decodeErr
+		}
+		return nil, fmt.Errorf("hms: response carried no result code")
+	}
+
+	return p.mapResults(tokens, hmsResp, parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())), nil
+}
+
+// buildRequest serializes the messages:send request for tokens.
+func (p *HMSProvider) buildRequest(tokens []string, payload *PushPayload) ([]byte, error) {
+	message := map[string]interface{}{
+		"token": tokens,
+	}
+	if payload.Kind.IsAlert() {
+		message["notification"] = map[string]string{
+			"title": payload.Title,
+			"body":  payload.Body,
+		}
+		message["android"] = map[string]interface{}{
+			"notification": hmsAndroidNotification(payload),
+		}
+	} else {
+		// Without a notification block Push Kit treats this as a data message,
+		// which must carry data.
+		message["data"] = "{}"
+	}
+	if android := hmsDeliveryOptions(payload); len(android) > 0 {
+		mergeMaps(message, map[string]interface{}{"android": android})
+	}
+	if len(payload.Data) > 0 {
+		// HMS expects custom data as a JSON encoded string.
+		data, err := json.Marshal(payload.Data)
+		if err != nil {
+			return nil, err
+		}
+		message["data"] = string(data)
+	}
+	if overrides := providerOverrides(payload.Overrides, p.Name()); overrides != nil {
+		mergeMaps(message, overrides)
+	}
+
+	request := map[string]interface{}{"message": message}
+	if payload.DryRun {
+		request["validate_only"] = true
+	}
+	return json.Marshal(request)
+}
+
+// PayloadSize measures the request without tokens against the Push Kit
+// message limit.
+func (p *HMSProvider) PayloadSize(payload *PushPayload) (int, int, error) {
+	body, err := p.buildRequest(nil, payload)
+	if err != nil {
+		return 0, 0, err
+	}
+	return len(body), hmsMaxPayloadBytes, nil
+}
+
+// mapResults turns the request level HMS code into per-token results. Partial
+// success lists the rejected tokens inside msg.
+func (p *HMSProvider) mapResults(tokens []string, hmsResp hmsResponse, retryAfter time.Duration) []models.PushResult {
+	failed := make(map[string]string)
+	switch hmsResp.Code {
+	case hmsSuccess:
+	case hmsPartialSuccess:
+		var detail struct {
+			IllegalTokens []string `json:"illegal_tokens"`
+		}
+		if err := json.Unmarshal([]byte(hmsResp.Msg), &detail); err != nil {
+			p.logger.Warn("hms partial success without token detail", slog.String("msg", hmsResp.Msg))
+		}
+		for _, token := range detail.IllegalTokens {
+			failed[token] = hmsInvalidToken
+		}
+	default:
+		for _, token := range tokens {
+			failed[token] = hmsResp.Code
+		}
+	}
+
+	results := make([]models.PushResult, 0, len(tokens))
+	for _, token := range tokens {
+		res := models.PushResult{
+			Token:     token,
+			Provider:  p.Name(),
+			Status:    models.ResultDelivered,
+			MessageID: hmsResp.RequestID,
+		}
+		if code, ok := failed[token]; ok {
+			res.Status = models.ResultFailed
+			res.Error = code
+			res.RetryAfter = retryAfter
+		}
+		results = append(results, res)
+	}
+	return results
+}
+
+func hmsDeliveryOptions(payload *PushPayload) map[string]interface{} {
+	android := map[string]interface{}{}
+	if payload.TTL > 0 {
+		android["ttl"] = fmt.Sprintf("%ds", int(payload.TTL/time.Second))
+	}
+	switch payload.Priority {
+	case PriorityHigh:
+		android["urgency"] = "HIGH"
+	case PriorityNormal:
+		android["urgency"] = "NORMAL"
+	}
+	return android
+}
+
+// hmsMaxButtons is the Push Kit limit for android.notification.buttons.
+const hmsMaxButtons = 3
+
+func hmsAndroidNotification(payload *PushPayload) map[string]interface{} {
+	opts := payload.Notification
+	notification := map[string]interface{}{
+		"title": payload.Title,
+		"body":  payload.Body,
+		// type 3 opens the app, which HMS requires for notification messages.
+		"click_action": map[string]interface{}{"type": 3},
+	}
+	switch {
+	case strings.HasPrefix(opts.ClickAction, "http://"), strings.HasPrefix(opts.ClickAction, "https://"):
+		notification["click_action"] = map[string]interface{}{"type": 2, "url": opts.ClickAction}
+	case opts.ClickAction != "":
+		notification["click_action"] = map[string]interface{}{"type": 1, "intent": opts.ClickAction}
+	}
+	if opts.ImageURL != "" {
+		notification["image"] = opts.ImageURL
+	}
+	if opts.Sound != "" {
+		notification["sound"] = opts.Sound
+		notification["default_sound"] = false
+	}
+	if opts.ChannelID != "" {
+		notification["channel_id"] = opts.ChannelID
+	}
+	if opts.ThreadID != "" {
+		notification["group"] = opts.ThreadID
+	}
+	if len(opts.Actions) > 0 {
+		buttons := make([]map[string]interface{}, 0, hmsMaxButtons)
+		for _, action := range opts.Actions {
+			if len(buttons) == hmsMaxButtons {
+				break
+			}
+			// action_type 0 opens the app, 1 opens the intent given.
+			button := map[string]interface{}{"name": action.Title, "action_type": 0}
+			if action.URL != "" {
+				button["action_type"] = 1
+				button["intent"] = action.URL
+			}
+			buttons = append(buttons, button)
+		}
+		notification["buttons"] = buttons
+	}
+	return notification
+}
+
+func (p *HMSProvider) accessToken(ctx context.Context) (string, error) {
+	return p.tokens.Get(ctx, func(ctx context.Context) (string, time.Duration, error) {
+		form := url.Values{}
+		form.Set("grant_type", "client_credentials")
+		form.Set("client_id", p.clientID)
+		form.Set("client_secret", p.clientSecret)
+		return requestAccessToken(ctx, p.client, p.tokenURL, form)
+	})
+}
+
+type hmsResponse struct {
+	Code      string `json:"code"`
+	Msg       string `json:"msg"`
+	RequestID string `json:"requestId"`
+}
@@ -0,0 +1,130 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/CyberwizD/Distributed-Notification-System/services/push_service/internal/models"
+)
+
+const (
+	// mockAnyToken scripts an error for every token without its own entry.
+	mockAnyToken = "*"
+	// mockMaxSends bounds the recorded sends so a long running consumer in
+	// mock mode does not grow without limit.
+	mockMaxSends = 1000
+)
+
+// MockProvider records sends in memory instead of calling a real provider. It
+// is meant for local runs and end-to-end tests; per-token failures are scripted
+// with provider error codes, e.g. "NotRegistered" or "HTTP_503".
+type MockProvider struct {
+	logger *slog.Logger
+
+	mu     sync.Mutex
+	errors map[string]string
+	sends  []PushPayload
+	nextID int
+}
+
+func NewMockProvider(errors map[string]string, logger *slog.Logger) *MockProvider {
+	scripted := make(map[string]string, len(errors))
+	for token, code := range errors {
+		scripted[token] = code
+	}
+	return &MockProvider{
+		logger: logger,
+		errors: scripted,
+	}
+}
+
+func (p *MockProvider) Name() string {
+	return "mock"
+}
+
+// SupportsDryRun lets dry runs reach the mock so they are recorded.
+func (p *MockProvider) SupportsDryRun() bool {
+	return true
+}
+
+// SupportsKind lets every message kind reach the mock.
+func (p *MockProvider) SupportsKind(kind MessageKind) bool {
+	return true
+}
+
+// Script makes every later send to token fail with code. An empty code clears
+// the entry; the token "*" applies to all tokens without their own entry.
+func (p *MockProvider) Script(token, code string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if code == "" {
+		delete(p.errors, token)
+		return
+	}
+	p.errors[token] = code
+}
+
+// Sends returns a copy of the payloads sent so far, the most recent
+// mockMaxSends of them.
+func (p *MockProvider) Sends() []PushPayload {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]PushPayload(nil), p.sends...)
+}
+
+// Reset forgets recorded sends and scripted errors.
+func (p *MockProvider) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sends = nil
+	p.errors = make(map[string]string)
+}
+
+func (p *MockProvider) Send(ctx context.Context, payload *PushPayload) ([]models.PushResult, error) {
+	if len(payload.Tokens) == 0 {
+		return nil, fmt.Errorf("mock: no tokens supplied")
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	recorded := *payload
+	recorded.Tokens = append([]models.PushToken(nil), payload.Tokens...)
+	if len(p.sends) >= mockMaxSends {
+		n := copy(p.sends, p.sends[1:])
+		p.sends = p.sends[:n]
+	}
+	p.sends = append(p.sends, recorded)
+
+	results := make([]models.PushResult, 0, len(payload.Tokens))
+	for _, token := range payload.Tokens {
+		res := models.PushResult{
+			Token:    token.Token,
+			Provider: p.Name(),
+			Status:   models.ResultDelivered,
+		}
+		code, ok := p.errors[token.Token]
+		if !ok {
+			code = p.errors[mockAnyToken]
+		}
+		if code != "" {
+			res.Status = models.ResultFailed
+			res.Error = code
+		} else {
+			p.nextID++
+			res.MessageID = fmt.Sprintf("mock-%d", p.nextID)
+		}
+		results = append(results, res)
+	}
+
+	p.logger.Info("mock push sent",
+		slog.Int("tokens", len(payload.Tokens)),
+		slog.String("title", payload.Title),
+	)
+	return results, nil
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenRefreshSkew refreshes cached access tokens slightly before they expire.
+const tokenRefreshSkew = time.Minute
+
+// accessTokenCache holds an OAuth2 access token until shortly before it
+// expires. Concurrent callers share a single refresh.
+type accessTokenCache struct {
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+// Get returns the cached token or calls mint for a new one.
+func (c *accessTokenCache) Get(ctx context.Context, mint func(context.Context) (string, time.Duration, error)) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token != "" && time.Now().Add(tokenRefreshSkew).Before(c.expiresAt) {
+		return c.token, nil
+	}
+
+	token, ttl, err := mint(ctx)
+	if err != nil {
+		return "", err
+	}
+	c.token = token
+	c.expiresAt = time.Now().Add(ttl)
+	return token, nil
+}
+
+// Invalidate drops the cached token so the next call mints a fresh one.
+func (c *accessTokenCache) Invalidate() {
+	c.mu.Lock()
+	c.token = ""
+	c.expiresAt = time.Time{}
+	c.mu.Unlock()
+}
+
+// requestAccessToken posts a form-encoded OAuth2 token request and decodes the
+// standard access_token/expires_in response.
+func requestAccessToken(ctx context.Context, client *http.Client, tokenURL string, form url.Values) (string, time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", 0, fmt.Errorf("oauth: token endpoint returned %d", resp.StatusCode)
+	}
+
+	var tokenResp struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return "", 0, err
+	}
+	if tokenResp.AccessToken == "" {
+		return "", 0, fmt.Errorf("oauth: token endpoint returned no access token")
+	}
+	if tokenResp.ExpiresIn <= 0 {
+		tokenResp.ExpiresIn = 3600
+	}
+	return tokenResp.AccessToken, time.Duration(tokenResp.ExpiresIn) * time.Second, nil
+}
@@ -1 +1,258 @@
 package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/CyberwizD/Distributed-Notification-System/services/push_service/internal/models"
+)
+
+const (
+	defaultOneSignalEndpoint = "https://onesignal.com/api/v1/notifications"
+	// oneSignalMaxPlayersPerRequest is the include_player_ids limit.
+	oneSignalMaxPlayersPerRequest = 2000
+)
+
+// OneSignalProvider sends notifications to OneSignal player IDs via the REST
+// notifications API.
+type OneSignalProvider struct {
+	appID       string
+	restKey     string
+	endpoint    string
+	client      *http.Client
+	logger      *slog.Logger
+	concurrency int
+}
+
+func NewOneSignalProvider(appID, restKey, endpoint string, timeout time.Duration, concurrency int, logger *slog.Logger) *OneSignalProvider {
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	if concurrency <= 0 {
+		concurrency = defaultSendConcurrency
+	}
+	if endpoint == "" {
+		endpoint = defaultOneSignalEndpoint
+	}
+	return &OneSignalProvider{
+		appID:    appID,
+		restKey:  restKey,
+		endpoint: endpoint,
+		client: &http.Client{
+			Timeout: timeout,
+		},
+		logger:      logger,
+		concurrency: concurrency,
+	}
+}
+
+func (p *OneSignalProvider) Name() string {
+	return "onesignal"
+}
+
+func (p *OneSignalProvider) Send(ctx context.Context, payload *PushPayload) ([]models.PushResult, error) {
+	if len(payload.Tokens) == 0 {
+		return nil, fmt.Errorf("onesignal: no tokens supplied")
+	}
+
+	tokens := nonEmptyTokens(payload.Tokens)
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("onesignal: tokens were empty")
+	}
+
+	return sendChunked(ctx, tokens, oneSignalMaxPlayersPerRequest, p.concurrency, func(ctx context.Context, chunk []models.PushToken) ([]models.PushResult, error) {
+		return p.sendChunk(ctx, chunk, payload)
+	})
+}
+
+func (p *OneSignalProvider) sendChunk(ctx context.Context, chunk []models.PushToken, payload *PushPayload) ([]models.PushResult, error) {
+	playerIDs := make([]string, 0, len(chunk))
+	for _, token := range chunk {
+		playerIDs = append(playerIDs, token.Token)
+	}
+
+	reqMap := map[string]interface{}{
+		"app_id":             p.appID,
+		"include_player_ids": playerIDs,
+	}
+	if payload.Kind.IsAlert() {
+		reqMap["headings"] = map[string]string{"en": payload.Title}
+		reqMap["contents"] = map[string]string{"en": payload.Body}
+		oneSignalNotification(reqMap, payload.Notification)
+	} else {
+		reqMap["content_available"] = true
+	}
+	if payload.TTL > 0 {
+		reqMap["ttl"] = int(payload.TTL / time.Second)
+	}
+	if payload.CollapseKey != "" {
+		reqMap["collapse_id"] = payload.CollapseKey
+		reqMap["web_push_topic"] = webPushTopic(payload.CollapseKey)
+	}
+	switch payload.Priority {
+	case PriorityHigh:
+		reqMap["priority"] = 10
+	case PriorityNormal:
+		reqMap["priority"] = 5
+	}
+	if len(payload.Data) > 0 {
+		reqMap["data"] = payload.Data
+	}
+	if overrides := providerOverrides(payload.Overrides, p.Name()); overrides != nil {
+		mergeMaps(reqMap, overrides)
+	}
+
+	body, err := json.Marshal(reqMap)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Basic "+p.restKey)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var osResp oneSignalResponse
+	decodeErr := json.NewDecoder(resp.Body).Decode(&osResp)
+
+	if resp.StatusCode >= 400 {
+		return nil, &StatusError{
+			Provider:   p.Name(),
+			StatusCode: resp.StatusCode,
+			Detail:     strings.Join(osResp.errorMessages(), "; "),
+			Wait:       parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		}
+	}
+	if decodeErr != nil {
+		return nil, decodeErr
+	}
+
+	invalid := make(map[string]bool)
+	for _, id := range osResp.invalidPlayerIDs() {
+		invalid[id] = true
+	}
+
+	// OneSignal reports "All included players are not subscribed" without an id
+	// when nobody could be targeted. Any other error without an id rejected the
+	// request, not the players, so it is returned for the whole batch.
+	var notSubscribed bool
+	if messages := osResp.errorMessages(); osResp.ID == "" && len(messages) > 0 {
+		if len(messages) != 1 || messages[0] != oneSignalNotSubscribed {
+			return nil, &ProviderError{
+				Provider: p.Name(),
+				Code:     "OneSignalError",
+				Class:    ErrorRetryable,
+				Err:      errors.New(strings.Join(messages, "; ")),
+			}
+		}
+		notSubscribed = true
+	}
+
+	results := make([]models.PushResult, 0, len(playerIDs))
+	for _, id := range playerIDs {
+		res := models.PushResult{
+			Token:     id,
+			Provider:  p.Name(),
+			Status:    models.ResultDelivered,
+			MessageID: osResp.ID,
+		}
+		switch {
+		case invalid[id]:
+			res.Status = models.ResultFailed
+			res.Error = "InvalidPlayerId"
+		case notSubscribed:
+			res.Status = models.ResultFailed
+			res.Error = "NotSubscribed"
+		}
+		results = append(results, res)
+	}
+
+	return results, nil
+}
+
+// oneSignalNotSubscribed is the error OneSignal returns when none of the
+// included players can receive notifications.
+const oneSignalNotSubscribed = "All included players are not subscribed"
+
+// oneSignalResponse models the notifications API response. "errors" is either
+// an array of messages or an object such as {"invalid_player_ids": [...]}.
+type oneSignalResponse struct {
+	ID         string          `json:"id"`
+	Recipients int             `json:"recipients"`
+	Errors     json.RawMessage `json:"errors"`
+}
+
+func (r oneSignalResponse) invalidPlayerIDs() []string {
+	var obj struct {
+		InvalidPlayerIDs []string `json:"invalid_player_ids"`
+	}
+	if len(r.Errors) == 0 || json.Unmarshal(r.Errors, &obj) != nil {
+		return nil
+	}
+	return obj.InvalidPlayerIDs
+}
+
+func (r oneSignalResponse) errorMessages() []string {
+	var messages []string
+	if len(r.Errors) == 0 || json.Unmarshal(r.Errors, &messages) != nil {
+		return nil
+	}
+	return messages
+}
+
+// oneSignalNotification maps the presentation fields onto OneSignal's
+// per-platform notification parameters.
+func oneSignalNotification(reqMap map[string]interface{}, opts models.NotificationOptions) {
+	if opts.ImageURL != "" {
+		reqMap["big_picture"] = opts.ImageURL
+		reqMap["chrome_web_image"] = opts.ImageURL
+		reqMap["ios_attachments"] = map[string]string{"image": opts.ImageURL}
+	}
+	if opts.ClickAction != "" {
+		reqMap["url"] = opts.ClickAction
+	}
+	if len(opts.Actions) > 0 {
+		buttons := make([]map[string]string, 0, len(opts.Actions))
+		for _, action := range opts.Actions {
+			button := map[string]string{"id": action.ID, "text": action.Title}
+			if action.Icon != "" {
+				button["icon"] = action.Icon
+			}
+			buttons = append(buttons, button)
+		}
+		reqMap["buttons"] = buttons
+	}
+	if opts.Sound != "" {
+		reqMap["ios_sound"] = opts.Sound
+		reqMap["android_sound"] = opts.Sound
+	}
+	if opts.Badge != nil {
+		reqMap["ios_badgeType"] = "SetTo"
+		reqMap["ios_badgeCount"] = *opts.Badge
+	}
+	if opts.ChannelID != "" {
+		reqMap["android_channel_id"] = opts.ChannelID
+	}
+	if opts.ThreadID != "" {
+		reqMap["thread_id"] = opts.ThreadID
+		reqMap["android_group"] = opts.ThreadID
+	}
+	if opts.Category != "" {
+		reqMap["ios_category"] = opts.Category
+	}
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/CyberwizD/Distributed-Notification-System/services/push_service/internal/models"
+)
+
+func TestOneSignalErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		response  string
+		wantErr   bool
+		wantCodes []string
+	}{
+		{
+			name:      "not subscribed",
+			response:  `{"id":"","recipients":0,"errors":["All included players are not subscribed"]}`,
+			wantCodes: []string{"NotSubscribed", "NotSubscribed"},
+		},
+		{
+			name:     "request rejected",
+			response: `{"id":"","recipients":0,"errors":["Message Notifications must have English language content"]}`,
+			wantErr:  true,
+		},
+		{
+			name:      "invalid player",
+			response:  `{"id":"n1","recipients":1,"errors":{"invalid_player_ids":["p2"]}}`,
+			wantCodes: []string{"", "InvalidPlayerId"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = io.WriteString(w, tc.response)
+			}))
+			defer server.Close()
+
+			provider := NewOneSignalProvider("app", "key", server.URL, 0, 0, slog.New(slog.NewTextHandler(io.Discard, nil)))
+			results, err := provider.Send(context.Background(), &PushPayload{
+				Title:  "Hi",
+				Body:   "Hello",
+				Tokens: []models.PushToken{{Token: "p1"}, {Token: "p2"}},
+			})
+			if tc.wantErr {
+				if err == nil || ClassOf(err) != ErrorRetryable {
+					t.Fatalf("Send error = %v, want a retryable error", err)
+				}
+				if len(results) != 0 {
+					t.Errorf("Send results = %v, want none", results)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) != len(tc.wantCodes) {
+				t.Fatalf("Send returned %d results, want %d", len(results), len(tc.wantCodes))
+			}
+			for i, res := range results {
+				if res.Error != tc.wantCodes[i] {
+					t.Errorf("result %s error = %q, want %q", res.Token, res.Error, tc.wantCodes[i])
+				}
+			}
+		})
+	}
+}
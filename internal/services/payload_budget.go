@@ -0,0 +1,135 @@
+package services
+
+import (
+	"fmt"
+	"unicode"
+)
+
+// TruncationPolicy controls how an oversized payload is shrunk to fit a
+// provider's size limit before it is sent.
+type TruncationPolicy struct {
+	// TruncateBody allows shortening Body, on grapheme boundaries.
+	TruncateBody bool
+	// Ellipsis is appended to a truncated body.
+	Ellipsis string
+}
+
+// fitPayload shrinks payload until limiter accepts its size: optional data
+// keys are dropped first, in order, then the body is truncated. It returns a
+// message-fatal MessageTooBig error when the payload still does not fit.
+// payload.Data is copied before keys are removed.
+func fitPayload(limiter PayloadLimiter, payload *PushPayload, policy TruncationPolicy) error {
+	size, limit, err := limiter.PayloadSize(payload)
+	if err != nil || size <= limit {
+		return err
+	}
+
+	copied := false
+	for _, key := range payload.OptionalData {
+		if _, ok := payload.Data[key]; !ok {
+			continue
+		}
+		if !copied {
+			payload.Data = copyStringMap(payload.Data)
+			copied = true
+		}
+		delete(payload.Data, key)
+		if size, limit, err = limiter.PayloadSize(payload); err != nil || size <= limit {
+			return err
+		}
+	}
+
+	if policy.TruncateBody && payload.Body != "" {
+		body := payload.Body
+		bounds := graphemeBoundaries(body)
+		// Find the longest prefix that fits; JSON escaping makes the size of a
+		// prefix non-linear, so each candidate is measured.
+		lo, hi := 0, len(bounds)-1
+		best := -1
+		for lo <= hi {
+			mid := (lo + hi) / 2
+			payload.Body = body[:bounds[mid]] + policy.Ellipsis
+			if size, limit, err = limiter.PayloadSize(payload); err != nil {
+				return err
+			}
+			if size <= limit {
+				best = mid
+				lo = mid + 1
+			} else {
+				hi = mid - 1
+			}
+		}
+		if best >= 0 {
+			payload.Body = body[:bounds[best]] + policy.Ellipsis
+			return nil
+		}
+		payload.Body = body
+	}
+
+	return &ProviderError{
+		Code:  "MessageTooBig",
+		Class: ErrorMessageFatal,
+		Err:   fmt.Errorf("payload is %d bytes, limit is %d", size, limit),
+	}
+}
+
+// graphemeBoundaries returns the byte offsets at which s may be cut without
+// splitting a user-perceived character, starting with 0 and excluding len(s).
+// It keeps combining marks, variation selectors, emoji modifiers and tags,
+// zero width joiner sequences and regional indicator pairs together.
+func graphemeBoundaries(s string) []int {
+	bounds := []int{0}
+	var prev rune
+	regional := 0
+	for i, r := range s {
+		if i == 0 {
+			prev = r
+			if isRegionalIndicator(r) {
+				regional = 1
+			}
+			continue
+		}
+		join := extendsGrapheme(r) || prev == '\u200d'
+		if isRegionalIndicator(r) {
+			if regional%2 == 1 {
+				join = true
+			}
+			regional++
+		} else {
+			regional = 0
+		}
+		if !join {
+			bounds = append(bounds, i)
+		}
+		prev = r
+	}
+	return bounds
+}
+
+func extendsGrapheme(r rune) bool {
+	switch {
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc):
+		return true
+	case r == '\u200d' || r == '\u200c':
+		return true
+	case r >= 0xFE00 && r <= 0xFE0F, r >= 0xE0100 && r <= 0xE01EF: // variation selectors
+		return true
+	case r >= 0x1F3FB && r <= 0x1F3FF: // emoji skin tone modifiers
+		return true
+	case r >= 0xE0020 && r <= 0xE007F: // emoji tag sequences
+		return true
+	}
+	return false
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
+
+func copyStringMap(src map[string]string) map[string]string {
+	dst := make(map[string]string, len(src))
+	for k, v := range src {
+		dst[k] = v
+	}
+	return dst
+}
@@ -2,17 +2,116 @@ package services
 
 import (
 	"context"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/CyberwizD/Distributed-Notification-System/services/push_service/internal/models"
 )
 
+// MessageKind selects how a provider presents the message on the device.
+type MessageKind string
+
+const (
+	// KindAlert shows Title and Body to the user.
+	KindAlert MessageKind = "alert"
+	// KindData delivers only Data to the app, without a visible notification.
+	KindData MessageKind = "data"
+	// KindBackground wakes the app for background work (APNs content-available).
+	KindBackground MessageKind = "background"
+	// KindLiveActivity starts, updates or ends an iOS Live Activity.
+	KindLiveActivity MessageKind = "liveactivity"
+	// KindVoIP is a PushKit VoIP push for incoming calls.
+	KindVoIP MessageKind = "voip"
+)
+
+// ParseMessageKind validates an envelope kind; empty means KindAlert.
+func ParseMessageKind(kind string) (MessageKind, error) {
+	switch MessageKind(strings.ToLower(kind)) {
+	case "", KindAlert:
+		return KindAlert, nil
+	case KindData:
+		return KindData, nil
+	case KindBackground:
+		return KindBackground, nil
+	case KindLiveActivity:
+		return KindLiveActivity, nil
+	case KindVoIP:
+		return KindVoIP, nil
+	default:
+		return "", fmt.Errorf("unknown message kind %q", kind)
+	}
+}
+
+// eligible reports whether token can receive this kind: VoIP and Live
+// Activity pushes need their own token types, which receive nothing else.
+func (k MessageKind) eligible(token models.PushToken) bool {
+	switch k {
+	case KindVoIP:
+		return token.Type == models.TokenTypeVoIP
+	case KindLiveActivity:
+		return token.Type == models.TokenTypeLiveActivity
+	default:
+		return token.Type == "" || token.Type == models.TokenTypeDevice
+	}
+}
+
+// IsAlert reports whether the message carries a visible notification.
+func (k MessageKind) IsAlert() bool {
+	return k == "" || k == KindAlert
+}
+
+// Priority is the delivery urgency requested for a message.
+type Priority string
+
+const (
+	PriorityHigh   Priority = "high"
+	PriorityNormal Priority = "normal"
+)
+
+// ParsePriority validates an envelope priority; empty leaves the provider
+// default.
+func ParsePriority(priority string) (Priority, error) {
+	switch Priority(strings.ToLower(priority)) {
+	case "":
+		return "", nil
+	case PriorityHigh:
+		return PriorityHigh, nil
+	case PriorityNormal:
+		return PriorityNormal, nil
+	default:
+		return "", fmt.Errorf("unknown priority %q", priority)
+	}
+}
+
 // PushPayload is the fully rendered payload handed to a provider.
 type PushPayload struct {
-	Tokens    []models.PushToken
-	Title     string
-	Body      string
-	Data      map[string]string
-	Overrides map[string]interface{}
+	Tokens []models.PushToken
+	Title  string
+	Body   string
+	Data   map[string]string
+	// OptionalData lists Data keys that may be dropped, in order, to fit the
+	// provider's payload size limit.
+	OptionalData []string
+	Overrides    map[string]interface{}
+	Kind         MessageKind
+	// LiveActivity is set for KindLiveActivity.
+	LiveActivity *models.LiveActivity
+	// Notification carries the rich presentation fields (image, actions,
+	// sound, badge, channel...) that providers translate to their own format.
+	Notification models.NotificationOptions
+	// TTL bounds how long providers keep trying to deliver; zero leaves the
+	// provider default.
+	TTL         time.Duration
+	CollapseKey string
+	Priority    Priority
+	// DryRun asks providers to validate the message without delivering it.
+	DryRun bool
+}
+
+// TokenEventPublisher publishes device token lifecycle events to other services.
+type TokenEventPublisher interface {
+	PublishTokenRotated(ctx context.Context, event models.TokenRotatedEvent) error
 }
 
 // PushProvider represents a downstream push provider (FCM, OneSignal, etc).
@@ -20,3 +119,35 @@ type PushProvider interface {
 	Name() string
 	Send(ctx context.Context, payload *PushPayload) ([]models.PushResult, error)
 }
+
+// TokenClaimer is implemented by providers whose tokens are recognisable by
+// their format (e.g. Expo push tokens) whatever platform they report.
+type TokenClaimer interface {
+	Claims(token models.PushToken) bool
+}
+
+// PayloadLimiter is implemented by providers with a payload size limit so
+// oversized messages are shrunk or rejected before any network round trip.
+type PayloadLimiter interface {
+	// PayloadSize returns the serialized size of payload and the limit, in bytes.
+	PayloadSize(payload *PushPayload) (size, limit int, err error)
+}
+
+// KindSupporter is implemented by providers that send more than the alert,
+// data and background kinds every provider handles.
+type KindSupporter interface {
+	SupportsKind(kind MessageKind) bool
+}
+
+// DryRunValidator is implemented by providers with a validate-only mode whose
+// Send honours PushPayload.DryRun. Other providers are not called during a dry
+// run.
+type DryRunValidator interface {
+	SupportsDryRun() bool
+}
+
+// BackgroundRunner is implemented by providers with background work, such as
+// receipt polling, that runs until ctx is cancelled.
+type BackgroundRunner interface {
+	Run(ctx context.Context)
+}
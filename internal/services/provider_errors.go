@@ -0,0 +1,261 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CyberwizD/Distributed-Notification-System/services/push_service/internal/models"
+)
+
+// ErrorClass describes what a provider failure means for the delivery.
+type ErrorClass int
+
+const (
+	// ErrorRetryable failures are transient (5xx, throttling, transport) and
+	// the same request may succeed later.
+	ErrorRetryable ErrorClass = iota
+	// ErrorTokenFatal failures mean the device token is permanently unusable.
+	ErrorTokenFatal
+	// ErrorMessageFatal failures mean the message itself is invalid and will
+	// be rejected for every token on every attempt.
+	ErrorMessageFatal
+)
+
+func (c ErrorClass) String() string {
+	switch c {
+	case ErrorTokenFatal:
+		return "token_fatal"
+	case ErrorMessageFatal:
+		return "message_fatal"
+	default:
+		return "retryable"
+	}
+}
+
+var tokenFatalCodes = map[string]bool{
+	// FCM legacy
+	"NotRegistered":       true,
+	"InvalidRegistration": true,
+	"MismatchSenderId":    true,
+	"MissingRegistration": true,
+	// FCM v1
+	"UNREGISTERED":       true,
+	"SENDER_ID_MISMATCH": true,
+	// Only for a malformed token; a malformed message is a StatusError.
+	"INVALID_ARGUMENT": true,
+	// APNs
+	"BadDeviceToken":         true,
+	"Unregistered":           true,
+	"DeviceTokenNotForTopic": true,
+	// OneSignal
+	"InvalidPlayerId": true,
+	"NotSubscribed":   true,
+	// HMS
+	hmsInvalidToken: true,
+	// Expo
+	"DeviceNotRegistered": true,
+	// Web Push
+	"SubscriptionGone":        true,
+	"MissingSubscriptionKeys": true,
+	"InvalidSubscriptionKeys": true,
+	"InvalidEndpoint":         true,
+}
+
+var messageFatalCodes = map[string]bool{
+	// FCM legacy
+	"MessageTooBig":      true,
+	"InvalidTtl":         true,
+	"InvalidDataKey":     true,
+	"InvalidPackageName": true,
+	// APNs
+	"PayloadTooLarge":   true,
+	"PayloadEmpty":      true,
+	"BadCollapseId":     true,
+	"BadExpirationDate": true,
+	"BadPriority":       true,
+	"BadTopic":          true,
+	"MissingTopic":      true,
+	"TopicDisallowed":   true,
+	"InvalidPushType":   true,
+	// HMS
+	hmsMessageTooBig:    true,
+	hmsInvalidParameter: true,
+	hmsInvalidMessage:   true,
+	// Expo
+	"InvalidCredentials": true,
+	// Push service
+	"UnsupportedPushType": true,
+	"InvalidLiveActivity": true,
+}
+
+// ClassifyErrorCode maps a per-token provider error code to its class.
+// Unknown codes are treated as retryable.
+func ClassifyErrorCode(code string) ErrorClass {
+	switch {
+	case tokenFatalCodes[code]:
+		return ErrorTokenFatal
+	case messageFatalCodes[code]:
+		return ErrorMessageFatal
+	case code == "HTTP_400" || code == "HTTP_413":
+		return ErrorMessageFatal
+	default:
+		return ErrorRetryable
+	}
+}
+
+// isProviderUnavailable reports whether a per-token error code means the
+// provider itself failed, so the token may succeed elsewhere.
+func isProviderUnavailable(code string) bool {
+	switch code {
+	case "Unavailable", "InternalServerError", "UNAVAILABLE", "INTERNAL",
+		"ServiceUnavailable", "Shutdown", hmsInternalError:
+		return true
+	}
+	return strings.HasPrefix(code, "HTTP_5")
+}
+
+// shouldFailover reports whether a provider level error means the provider is
+// unavailable (transport failure or 5xx) rather than rejecting the request.
+func shouldFailover(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500
+	}
+	return ClassOf(err) == ErrorRetryable
+}
+
+// ProviderError is a classified delivery failure. It satisfies the retry
+// package's Retryable contract so retries stop early on fatal failures.
+type ProviderError struct {
+	Provider string
+	Code     string
+	Class    ErrorClass
+	Err      error
+}
+
+func (e *ProviderError) Error() string {
+	msg := e.Code
+	if e.Err != nil {
+		msg = e.Err.Error()
+	}
+	if e.Provider != "" {
+		return fmt.Sprintf("%s: %s", e.Provider, msg)
+	}
+	return msg
+}
+
+func (e *ProviderError) Unwrap() error          { return e.Err }
+func (e *ProviderError) ErrorClass() ErrorClass { return e.Class }
+func (e *ProviderError) Retryable() bool        { return e.Class == ErrorRetryable }
+
+// StatusError reports an unexpected HTTP status returned by a provider for the
+// whole request.
+type StatusError struct {
+	Provider   string
+	StatusCode int
+	Detail     string
+	// Wait is the server requested delay from a Retry-After header.
+	Wait time.Duration
+}
+
+func (e *StatusError) Error() string {
+	if e.Detail != "" {
+		return fmt.Sprintf("%s: received status %d: %s", e.Provider, e.StatusCode, e.Detail)
+	}
+	return fmt.Sprintf("%s: received status %d", e.Provider, e.StatusCode)
+}
+
+// ErrorClass treats throttling, auth and server errors as retryable and any
+// other client error as a problem with the message.
+func (e *StatusError) ErrorClass() ErrorClass {
+	switch {
+	case e.StatusCode >= 500,
+		e.StatusCode == http.StatusTooManyRequests,
+		e.StatusCode == http.StatusUnauthorized,
+		e.StatusCode == http.StatusForbidden:
+		return ErrorRetryable
+	case e.StatusCode >= 400:
+		return ErrorMessageFatal
+	default:
+		return ErrorRetryable
+	}
+}
+
+func (e *StatusError) Retryable() bool { return e.ErrorClass() == ErrorRetryable }
+
+func (e *StatusError) RetryAfter() time.Duration { return e.Wait }
+
+// parseRetryAfter reads a Retry-After header in either delay-seconds or
+// HTTP-date form. Missing, invalid or past values yield zero.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds <= 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if d := at.Sub(now); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// ClassOf returns the class of err. Unclassified errors (transport failures,
+// timeouts) are retryable; a joined error is retryable if any part is.
+func ClassOf(err error) ErrorClass {
+	if err == nil {
+		return ErrorRetryable
+	}
+	if classified, ok := err.(interface{ ErrorClass() ErrorClass }); ok {
+		return classified.ErrorClass()
+	}
+	switch wrapped := err.(type) {
+	case interface{ Unwrap() []error }:
+		class := ErrorTokenFatal
+		for _, inner := range wrapped.Unwrap() {
+			switch ClassOf(inner) {
+			case ErrorRetryable:
+				return ErrorRetryable
+			case ErrorMessageFatal:
+				class = ErrorMessageFatal
+			}
+		}
+		return class
+	case interface{ Unwrap() error }:
+		return ClassOf(wrapped.Unwrap())
+	}
+	return ErrorRetryable
+}
+
+// failureClass summarises undelivered results: any message-fatal code makes
+// the whole message fatal, and only token-fatal codes make it token-fatal.
+func failureClass(results []models.PushResult) ErrorClass {
+	class := ErrorTokenFatal
+	failed := false
+	for _, res := range results {
+		if res.Status == models.ResultDelivered {
+			continue
+		}
+		failed = true
+		switch ClassifyErrorCode(res.Error) {
+		case ErrorMessageFatal:
+			return ErrorMessageFatal
+		case ErrorRetryable:
+			class = ErrorRetryable
+		}
+	}
+	if !failed {
+		return ErrorRetryable
+	}
+	return class
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/CyberwizD/Distributed-Notification-System/services/push_service/pkg/retry"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"120", 2 * time.Minute},
+		{" 5 ", 5 * time.Second},
+		{"0", 0},
+		{"-3", 0},
+		{"soon", 0},
+		{"1.5", 0},
+		{now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0},
+		{now.Format(http.TimeFormat), 0},
+		{"Sun, 18 Oct 2026 12:01:00 +0000", 0},
+	}
+	for _, tc := range tests {
+		if got := parseRetryAfter(tc.value, now); got != tc.want {
+			t.Errorf("parseRetryAfter(%q) = %s, want %s", tc.value, got, tc.want)
+		}
+	}
+}
+
+func TestStatusErrorRetryAfter(t *testing.T) {
+	err := errors.Join(
+		&StatusError{Provider: "fcm-v1", StatusCode: http.StatusTooManyRequests, Wait: time.Second},
+		&StatusError{Provider: "apns", StatusCode: http.StatusServiceUnavailable, Wait: 30 * time.Second},
+		&ProviderError{Provider: "hms", Code: "InvalidToken", Class: ErrorTokenFatal},
+	)
+	if !retry.Retryable(err) {
+		t.Error("Retryable = false, want true when any part is retryable")
+	}
+	if got, ok := retry.RetryAfter(err); !ok || got != 30*time.Second {
+		t.Errorf("RetryAfter = %s, %v, want the longest delay 30s", got, ok)
+	}
+}
+
+func TestRetryStopsOnFatalProviderError(t *testing.T) {
+	for _, class := range []ErrorClass{ErrorTokenFatal, ErrorMessageFatal} {
+		calls := 0
+		err := retry.Do(context.Background(), retry.Config{MaxAttempts: 5, InitialBackoff: time.Millisecond}, func() error {
+			calls++
+			return &ProviderError{Provider: "mock", Code: "Rejected", Class: class}
+		})
+		if calls != 1 || ClassOf(err) != class {
+			t.Errorf("%s: Do = %v after %d calls, want it returned after 1 call", class, err, calls)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/CyberwizD/Distributed-Notification-System/services/push_service/internal/models"
+)
+
+// ProviderRegistry resolves which PushProvider handles a given token, either by
+// the token's explicit provider name, a provider recognising the token format
+// or its platform default, and which provider to fall back to when that one is
+// unavailable.
+type ProviderRegistry struct {
+	providers map[string]PushProvider
+	aliases   map[string]string
+	platforms map[string]string
+	fallbacks map[string][]string
+	claimers  []PushProvider
+}
+
+func NewProviderRegistry() *ProviderRegistry {
+	return &ProviderRegistry{
+		providers: make(map[string]PushProvider),
+		aliases:   make(map[string]string),
+		platforms: make(map[string]string),
+		fallbacks: make(map[string][]string),
+	}
+}
+
+// Register adds a provider under its Name and makes it the default for the
+// supplied platforms. Later registrations for the same platform win.
+func (r *ProviderRegistry) Register(provider PushProvider, platforms ...string) {
+	r.providers[provider.Name()] = provider
+	if _, ok := provider.(TokenClaimer); ok {
+		r.claimers = append(r.claimers, provider)
+	}
+	for _, platform := range platforms {
+		r.platforms[strings.ToLower(platform)] = provider.Name()
+	}
+}
+
+// Alias lets tokens refer to a registered provider by another name, e.g. "fcm"
+// for the "fcm-v1" provider.
+func (r *ProviderRegistry) Alias(alias, name string) {
+	r.aliases[strings.ToLower(alias)] = name
+}
+
+// RunBackground starts the background work of every provider that has any.
+func (r *ProviderRegistry) RunBackground(ctx context.Context) {
+	for _, provider := range r.providers {
+		if runner, ok := provider.(BackgroundRunner); ok {
+			go runner.Run(ctx)
+		}
+	}
+}
+
+// SetFallbackChain configures the ordered providers tried for a platform, e.g.
+// android: fcm-v1, onesignal. Aliases are resolved so that a chain naming the
+// same provider twice is rejected rather than looping.
+func (r *ProviderRegistry) SetFallbackChain(platform string, chain ...string) error {
+	resolved := make([]string, 0, len(chain))
+	seen := make(map[string]bool, len(chain))
+	for _, name := range chain {
+		name = strings.ToLower(name)
+		if target, ok := r.aliases[name]; ok {
+			name = target
+		}
+		if seen[name] {
+			return fmt.Errorf("fallback chain for %s lists %s more than once", platform, name)
+		}
+		seen[name] = true
+		resolved = append(resolved, name)
+	}
+	r.fallbacks[strings.ToLower(platform)] = resolved
+	return nil
+}
+
+// Next returns the provider that follows current in the token's platform
+// fallback chain, skipping providers that are not registered, were already
+// tried or cannot address the token.
+func (r *ProviderRegistry) Next(token models.PushToken, current string, tried map[string]bool) (PushProvider, bool) {
+	chain := r.fallbacks[strings.ToLower(token.Platform)]
+	found := false
+	for _, name := range chain {
+		provider, ok := r.Get(name)
+		if !ok {
+			continue
+		}
+		if found && !tried[provider.Name()] && addresses(provider, token) {
+			return provider, true
+		}
+		found = found || provider.Name() == current
+	}
+	return nil, false
+}
+
+// addresses reports whether provider can send to token. Device tokens are
+// issued by one provider and mean nothing to another, so only a provider
+// that recognises the token format can take it over.
+func addresses(provider PushProvider, token models.PushToken) bool {
+	claimer, ok := provider.(TokenClaimer)
+	return ok && claimer.Claims(token)
+}
+
+// IsHome reports whether provider is the one token resolves to, the only
+// provider whose rejection says anything about the token itself.
+func (r *ProviderRegistry) IsHome(token models.PushToken, provider string) bool {
+	home, ok := r.Resolve(token)
+	return ok && home.Name() == provider
+}
+
+// Get returns the provider registered under name or alias.
+func (r *ProviderRegistry) Get(name string) (PushProvider, bool) {
+	name = strings.ToLower(name)
+	if target, ok := r.aliases[name]; ok {
+		name = target
+	}
+	provider, ok := r.providers[name]
+	return provider, ok
+}
+
+// Resolve picks the provider for a token. An explicit token provider must be
+// registered; otherwise a provider claiming the token format wins over the
+// platform default.
+func (r *ProviderRegistry) Resolve(token models.PushToken) (PushProvider, bool) {
+	if token.Provider != "" {
+		return r.Get(token.Provider)
+	}
+	for _, provider := range r.claimers {
+		if provider.(TokenClaimer).Claims(token) {
+			return provider, true
+		}
+	}
+	name, ok := r.platforms[strings.ToLower(token.Platform)]
+	if !ok {
+		return nil, false
+	}
+	return r.Get(name)
+}
+
+// providerBatch groups the tokens routed to a single provider.
+type providerBatch struct {
+	provider PushProvider
+	tokens   []models.PushToken
+}
+
+// Split groups tokens by provider, preserving first-seen provider order and
+// the token order within each group. Tokens with no provider are returned
+// separately.
+func (r *ProviderRegistry) Split(tokens []models.PushToken) ([]providerBatch, []models.PushToken) {
+	return groupTokens(tokens, r.Resolve)
+}
+
+// SplitFallback groups tokens by the next untried provider in their fallback
+// chain after current. Tokens whose chain is exhausted are returned separately.
+func (r *ProviderRegistry) SplitFallback(tokens []models.PushToken, current string, tried map[string]bool) ([]providerBatch, []models.PushToken) {
+	return groupTokens(tokens, func(token models.PushToken) (PushProvider, bool) {
+		return r.Next(token, current, tried)
+	})
+}
+
+func groupTokens(tokens []models.PushToken, pick func(models.PushToken) (PushProvider, bool)) ([]providerBatch, []models.PushToken) {
+	var (
+		batches []providerBatch
+		rest    []models.PushToken
+		index   = make(map[string]int)
+	)
+	for _, token := range tokens {
+		provider, ok := pick(token)
+		if !ok {
+			rest = append(rest, token)
+			continue
+		}
+		i, ok := index[provider.Name()]
+		if !ok {
+			i = len(batches)
+			index[provider.Name()] = i
+			batches = append(batches, providerBatch{provider: provider})
+		}
+		batches[i].tokens = append(batches[i].tokens, token)
+	}
+	return batches, rest
+}
+
+// providerNames joins the participating provider names for status rows.
+func providerNames(batches []providerBatch) string {
+	names := make([]string, 0, len(batches))
+	for _, batch := range batches {
+		names = append(names, batch.provider.Name())
+	}
+	return strings.Join(names, ",")
+}
+
+// resultProviders joins the distinct providers that produced results, which
+// may differ from the routed providers after a fallback.
+func resultProviders(results []models.PushResult) string {
+	seen := make(map[string]bool, len(results))
+	names := make([]string, 0, 1)
+	for _, res := range results {
+		if res.Provider == "" || seen[res.Provider] {
+			continue
+		}
+		seen[res.Provider] = true
+		names = append(names, res.Provider)
+	}
+	return strings.Join(names, ",")
+}
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/CyberwizD/Distributed-Notification-System/services/push_service/internal/models"
+)
+
+// stubProvider is a named provider that claims tokens with its prefix when
+// prefix is set.
+type stubProvider struct {
+	name   string
+	prefix string
+}
+
+func (s stubProvider) Name() string { return s.name }
+
+func (s stubProvider) Send(context.Context, *PushPayload) ([]models.PushResult, error) {
+	return nil, nil
+}
+
+type claimingStub struct{ stubProvider }
+
+func (s claimingStub) Claims(token models.PushToken) bool {
+	return strings.HasPrefix(token.Token, s.prefix)
+}
+
+func TestProviderRegistryNextOnlyAddressableProviders(t *testing.T) {
+	registry := NewProviderRegistry()
+	registry.Register(stubProvider{name: "fcm-v1"}, "android")
+	registry.Register(stubProvider{name: "onesignal"})
+	registry.Register(claimingStub{stubProvider{name: "expo", prefix: "ExponentPushToken["}})
+	if err := registry.SetFallbackChain("android", "fcm-v1", "onesignal", "expo"); err != nil {
+		t.Fatal(err)
+	}
+
+	fcmToken := models.PushToken{Token: "fcm-token", Platform: "android"}
+	if next, ok := registry.Next(fcmToken, "fcm-v1", nil); ok {
+		t.Fatalf("Next(fcm token) = %s, want none", next.Name())
+	}
+
+	expoToken := models.PushToken{Token: "ExponentPushToken[abc]", Platform: "android", Provider: "fcm-v1"}
+	next, ok := registry.Next(expoToken, "fcm-v1", nil)
+	if !ok || next.Name() != "expo" {
+		t.Fatalf("Next(expo token) = %v, %v, want expo", next, ok)
+	}
+
+	if !registry.IsHome(expoToken, "fcm-v1") || registry.IsHome(expoToken, "expo") {
+		t.Fatal("IsHome should only accept the provider the token resolves to")
+	}
+}
+
+func TestProviderRegistryFallbackChainCycles(t *testing.T) {
+	registry := NewProviderRegistry()
+	registry.Register(stubProvider{name: "fcm-v1"}, "android")
+	registry.Register(claimingStub{stubProvider{name: "expo", prefix: "ExponentPushToken["}})
+	registry.Alias("fcm", "fcm-v1")
+
+	if err := registry.SetFallbackChain("android", "fcm-v1", "expo", "fcm"); err == nil {
+		t.Fatal("SetFallbackChain accepted a chain naming fcm-v1 twice through its alias")
+	}
+	if err := registry.SetFallbackChain("android", "fcm", "expo"); err != nil {
+		t.Fatal(err)
+	}
+
+	token := models.PushToken{Token: "ExponentPushToken[abc]", Platform: "android", Provider: "fcm"}
+	if next, ok := registry.Next(token, "fcm-v1", nil); !ok || next.Name() != "expo" {
+		t.Fatalf("Next = %v, %v, want expo", next, ok)
+	}
+	if next, ok := registry.Next(token, "fcm-v1", map[string]bool{"expo": true}); ok {
+		t.Fatalf("Next returned already tried provider %s", next.Name())
+	}
+}
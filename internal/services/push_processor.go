@@ -2,9 +2,13 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/CyberwizD/Distributed-Notification-System/services/push_service/internal/models"
 	"github.com/CyberwizD/Distributed-Notification-System/services/push_service/internal/repository"
@@ -14,104 +18,368 @@ import (
 
 type PushProcessor struct {
 	templateClient *TemplateClient
-	fcm            PushProvider
+	providers      *ProviderRegistry
 	statusUpdater  *StatusUpdater
 	cache          *repository.RedisRepository
+	tokenEvents    TokenEventPublisher
 	metrics        *metrics.Metrics
 	logger         *slog.Logger
 	retryCfg       retry.Config
+	dryRun         bool
+	truncation     TruncationPolicy
+	inline         InlineTemplatePolicy
+	renderer       *TemplateRenderer
 }
 
 func NewPushProcessor(
 	templateClient *TemplateClient,
-	fcm PushProvider,
+	providers *ProviderRegistry,
 	statusUpdater *StatusUpdater,
 	cache *repository.RedisRepository,
+	tokenEvents TokenEventPublisher,
 	metrics *metrics.Metrics,
 	logger *slog.Logger,
 	retryCfg retry.Config,
+	dryRun bool,
+	truncation TruncationPolicy,
+	inline InlineTemplatePolicy,
 ) *PushProcessor {
 	return &PushProcessor{
 		templateClient: templateClient,
-		fcm:            fcm,
+		providers:      providers,
 		statusUpdater:  statusUpdater,
 		cache:          cache,
+		tokenEvents:    tokenEvents,
 		metrics:        metrics,
 		logger:         logger,
 		retryCfg:       retryCfg,
+		dryRun:         dryRun,
+		truncation:     truncation,
+		inline:         inline,
+		renderer:       NewTemplateRenderer(0),
 	}
 }
 
-func (p *PushProcessor) Process(ctx context.Context, envelope *models.MessageEnvelope) error {
+// Process delivers envelope. producer identifies the publisher as reported by
+// the broker, never by the envelope itself, and gates inline templates.
+func (p *PushProcessor) Process(ctx context.Context, envelope *models.MessageEnvelope, producer string) error {
 	if envelope.Channel != "push" {
 		return fmt.Errorf("unexpected channel %s", envelope.Channel)
 	}
 	p.metrics.IncConsumed()
 
-	activeTokens, err := p.filterTokens(ctx, envelope.User.PushTokens)
+	kind, priority, err := deliveryOptions(envelope)
+	if err != nil {
+		p.statusUpdater.MarkFailed(ctx, envelope.RequestID, "", err.Error())
+		p.metrics.IncFailed()
+		return err
+	}
+
+	activeTokens, err := p.filterTokens(ctx, envelope.User.PushTokens, kind)
 	if err != nil {
 		p.logger.Error("failed to filter tokens", slog.Any("error", err))
 		return err
 	}
-	if len(activeTokens) == 0 {
-		err := fmt.Errorf("no valid push tokens")
-		p.statusUpdater.MarkFailed(ctx, envelope.RequestID, p.fcm.Name(), err.Error())
+	batches, unroutable := p.providers.Split(activeTokens)
+	for _, token := range unroutable {
+		p.logger.Debug("no provider for token", slog.String("request_id", envelope.RequestID),
+			slog.String("platform", token.Platform), slog.String("provider", token.Provider))
+	}
+	if len(batches) == 0 {
+		err := &ProviderError{Code: "NoValidTokens", Class: ErrorTokenFatal, Err: errors.New("no valid push tokens")}
+		p.statusUpdater.MarkFailed(ctx, envelope.RequestID, "", err.Error())
 		p.metrics.IncFailed()
 		return err
 	}
+	providers := providerNames(batches)
 
-	tpl, err := p.templateClient.Fetch(ctx, envelope.Template.Slug, localeFromEnvelope(envelope))
+	tpl, err := p.template(ctx, envelope, producer)
 	if err != nil {
-		p.statusUpdater.MarkFailed(ctx, envelope.RequestID, p.fcm.Name(), err.Error())
+		p.logger.Warn("failed to resolve template", slog.String("request_id", envelope.RequestID),
+			slog.String("slug", envelope.Template.Slug), slog.Int("template_version", envelope.Template.Version),
+			slog.String("producer", producer), slog.Any("error", err))
+		p.statusUpdater.MarkFailed(ctx, envelope.RequestID, providers, err.Error())
 		p.metrics.IncFailed()
 		return err
 	}
+	p.logger.Info("template resolved", slog.String("request_id", envelope.RequestID),
+		slog.String("slug", tpl.Slug), slog.String("locale", tpl.Locale), slog.Int("template_version", tpl.Version),
+		slog.Bool("inline", isInlineTemplate(envelope.Template)))
 
-	titleTemplate := tpl.Subject
-	if titleTemplate == "" {
-		titleTemplate = tpl.Slug
+	title, body, notification, err := p.render(tpl, envelope)
+	if err != nil {
+		err = &ProviderError{Code: "InvalidTemplate", Class: ErrorMessageFatal, Err: err}
+		p.logger.Warn("failed to render template", slog.String("request_id", envelope.RequestID),
+			slog.String("slug", tpl.Slug), slog.Int("template_version", tpl.Version), slog.Any("error", err))
+		p.statusUpdater.MarkFailed(ctx, envelope.RequestID, providers, err.Error())
+		p.metrics.IncFailed()
+		return err
 	}
-	title := RenderTemplate(titleTemplate, envelope.Variables)
-	body := RenderTemplate(tpl.Body, envelope.Variables)
 
 	payload := &PushPayload{
-		Tokens:    activeTokens,
-		Title:     title,
-		Body:      body,
-		Data:      toStringMap(envelope.Variables),
-		Overrides: envelope.ProviderOverrides,
+		Title:        title,
+		Body:         body,
+		Data:         toStringMap(envelope.Variables),
+		OptionalData: envelope.OptionalDataKeys,
+		Overrides:    envelope.ProviderOverrides,
+		Kind:         kind,
+		LiveActivity: envelope.LiveActivity,
+		TTL:          time.Duration(envelope.TTLSeconds) * time.Second,
+		CollapseKey:  envelope.CollapseKey,
+		Priority:     priority,
+		Notification: notification,
+		DryRun:       p.dryRun || envelope.DryRun,
+	}
+	if payload.DryRun {
+		p.logger.Info("dry run, providers will validate only", slog.String("request_id", envelope.RequestID))
 	}
 
-	p.statusUpdater.MarkProcessing(ctx, envelope.RequestID)
+	p.statusUpdater.MarkProcessing(ctx, envelope.RequestID, tpl.Version)
+
+	tracker := newDeliveryTracker(routedTokens(batches))
+	attempt := 0
 	sendErr := retry.Do(ctx, p.retryCfg, func() error {
-		results, err := p.fcm.Send(ctx, payload)
+		attempt++
+		pending := tracker.Pending()
+		if attempt > 1 {
+			p.metrics.IncRetried()
+			p.logger.Info("retrying undelivered tokens", slog.String("request_id", envelope.RequestID),
+				slog.Int("attempt", attempt), slog.Int("tokens", len(pending)))
+		}
+		pendingBatches, _ := p.providers.Split(pending)
+		results, err := p.sendAll(ctx, envelope, pendingBatches, payload)
+		tracker.Record(results)
+		if !payload.DryRun {
+			p.suppressFatalTokens(ctx, pending, results)
+			p.handleRotations(ctx, envelope, results)
+		}
+		if rejected := messageFatalError(results); rejected != nil {
+			return rejected
+		}
 		if err != nil {
-			p.logger.Warn("fcm send failed", slog.Any("error", err), slog.String("request_id", envelope.RequestID))
 			return err
 		}
-		return p.handleResults(ctx, envelope, results)
+		if remaining := tracker.Pending(); len(remaining) > 0 {
+			return tracker.PendingError(remaining)
+		}
+		return nil
 	})
 
-	if sendErr != nil {
+	return p.finish(ctx, envelope, tracker, providers, payload.DryRun, sendErr)
+}
+
+// Expire records that envelope was dropped unsent because it went stale.
+func (p *PushProcessor) Expire(ctx context.Context, envelope *models.MessageEnvelope, reason string) {
+	p.metrics.IncConsumed()
+	p.metrics.IncExpired()
+	p.logger.Info("dropping stale push", slog.String("request_id", envelope.RequestID), slog.String("reason", reason))
+	p.statusUpdater.MarkExpired(ctx, envelope.RequestID, reason)
+}
+
+// template returns the envelope's inline template, when it has one, or
+// fetches the referenced template.
+func (p *PushProcessor) template(ctx context.Context, envelope *models.MessageEnvelope, producer string) (*models.Template, error) {
+	if isInlineTemplate(envelope.Template) {
+		return inlineTemplate(envelope, producer, p.inline)
+	}
+	return p.templateClient.Fetch(ctx, envelope.Template.Slug, localeFromEnvelope(envelope), envelope.Template.Version)
+}
+
+// finish records the final status from the per-token outcomes. Partial
+// delivery is acknowledged rather than retried so delivered tokens are not
+// notified twice. Dry runs that validated for any token record dry_run.
+func (p *PushProcessor) finish(ctx context.Context, envelope *models.MessageEnvelope, tracker *deliveryTracker, providers string, dryRun bool, sendErr error) error {
+	results := tracker.Results()
+	if used := resultProviders(results); used != "" {
+		providers = used
+	}
+
+	delivered, failures := tracker.Summary()
+	switch {
+	case dryRun && delivered > 0:
+		p.metrics.IncDryRun()
+		var detail string
+		if len(failures) > 0 {
+			detail = "failed tokens: " + strings.Join(failures, ", ")
+		}
+		p.statusUpdater.MarkDryRun(ctx, envelope.RequestID, providers, detail)
+		return nil
+	case len(failures) == 0:
+		p.metrics.IncDelivered()
+		p.statusUpdater.MarkDelivered(ctx, envelope.RequestID, providers)
+		return nil
+	case delivered > 0:
+		p.metrics.IncDelivered()
+		detail := "failed tokens: " + strings.Join(failures, ", ")
+		p.logger.Warn("push partially delivered", slog.String("request_id", envelope.RequestID),
+			slog.Int("delivered", delivered), slog.Int("failed", len(failures)))
+		p.statusUpdater.MarkPartiallyDelivered(ctx, envelope.RequestID, providers, detail)
+		return nil
+	default:
+		if len(tracker.Pending()) == 0 && ClassOf(sendErr) == ErrorRetryable {
+			// Every token failed for a reason retrying cannot fix.
+			sendErr = &ProviderError{
+				Code:  "AllTokensFailed",
+				Class: failureClass(results),
+				Err:   fmt.Errorf("failed tokens: %s", strings.Join(failures, ", ")),
+			}
+		}
 		p.metrics.IncFailed()
-		p.statusUpdater.MarkFailed(ctx, envelope.RequestID, p.fcm.Name(), sendErr.Error())
+		p.statusUpdater.MarkFailed(ctx, envelope.RequestID, providers, sendErr.Error())
 		return sendErr
 	}
+}
 
-	p.metrics.IncDelivered()
-	return nil
+// sendAll sends each provider batch concurrently and merges the results.
+func (p *PushProcessor) sendAll(ctx context.Context, envelope *models.MessageEnvelope, batches []providerBatch, payload *PushPayload) ([]models.PushResult, error) {
+	type outcome struct {
+		results []models.PushResult
+		err     error
+	}
+	outcomes := make([]outcome, len(batches))
+
+	var wg sync.WaitGroup
+	for i, batch := range batches {
+		wg.Add(1)
+		go func(i int, batch providerBatch) {
+			defer wg.Done()
+			results, err := p.sendBatch(ctx, envelope, batch, payload, nil)
+			outcomes[i] = outcome{results: results, err: err}
+		}(i, batch)
+	}
+	wg.Wait()
+
+	var (
+		merged []models.PushResult
+		errs   []error
+	)
+	for _, o := range outcomes {
+		merged = append(merged, o.results...)
+		if o.err != nil {
+			errs = append(errs, o.err)
+		}
+	}
+	return merged, errors.Join(errs...)
+}
+
+// sendBatch sends to the batch provider and, when that provider is
+// unavailable, moves the affected tokens to the next provider in their
+// platform fallback chain. tried holds the providers already attempted for
+// these tokens, so a chain never revisits one.
+func (p *PushProcessor) sendBatch(ctx context.Context, envelope *models.MessageEnvelope, batch providerBatch, payload *PushPayload, tried map[string]bool) ([]models.PushResult, error) {
+	name := batch.provider.Name()
+	batchPayload := *payload
+	batchPayload.Tokens = batch.tokens
+
+	if limiter, ok := batch.provider.(PayloadLimiter); ok {
+		if err := fitPayload(limiter, &batchPayload, p.truncation); err != nil {
+			p.logger.Warn("payload does not fit provider limit", slog.String("provider", name),
+				slog.Any("error", err), slog.String("request_id", envelope.RequestID))
+			if ClassOf(err) != ErrorMessageFatal {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			return failedResults(name, batch.tokens, "MessageTooBig"), nil
+		}
+		if batchPayload.Body != payload.Body || len(batchPayload.Data) != len(payload.Data) {
+			p.logger.Info("payload shrunk to fit provider limit", slog.String("provider", name),
+				slog.String("request_id", envelope.RequestID))
+		}
+	}
+
+	if !supportsKind(batch.provider, payload.Kind) {
+		p.logger.Warn("provider does not support message kind", slog.String("provider", name),
+			slog.String("kind", string(payload.Kind)), slog.String("request_id", envelope.RequestID))
+		return failedResults(name, batch.tokens, "UnsupportedPushType"), nil
+	}
+
+	if payload.DryRun && !supportsDryRun(batch.provider) {
+		p.logger.Debug("dry run, skipping provider without validate-only mode",
+			slog.String("provider", name), slog.String("request_id", envelope.RequestID))
+		return dryRunResults(name, batch.tokens), nil
+	}
+
+	results, err := batch.provider.Send(ctx, &batchPayload)
+	if err != nil {
+		p.logger.Warn("provider send failed", slog.String("provider", name),
+			slog.Any("error", err), slog.String("request_id", envelope.RequestID))
+		if !strings.HasPrefix(err.Error(), name+":") {
+			err = fmt.Errorf("%s: %w", name, err)
+		}
+		if ctx.Err() != nil || !shouldFailover(err) {
+			return results, err
+		}
+	}
+
+	// Work out which tokens should move on: those the provider reported as
+	// unavailable plus, after a transport or 5xx error, those left without a
+	// result. Chunked providers may return partial results with an error.
+	var (
+		kept     []models.PushResult
+		failover []models.PushToken
+		failed   = make(map[string]models.PushResult)
+		answered = make(map[string]bool, len(results))
+	)
+	for _, res := range results {
+		answered[res.Token] = true
+		if res.Status != models.ResultDelivered && isProviderUnavailable(res.Error) {
+			failed[res.Token] = res
+			continue
+		}
+		kept = append(kept, res)
+	}
+	for _, token := range batch.tokens {
+		_, unavailable := failed[token.Token]
+		if unavailable || (err != nil && !answered[token.Token]) {
+			failover = append(failover, token)
+		}
+	}
+	if len(failover) == 0 {
+		return kept, err
+	}
+
+	attempted := make(map[string]bool, len(tried)+1)
+	for provider := range tried {
+		attempted[provider] = true
+	}
+	attempted[name] = true
+	next, stranded := p.providers.SplitFallback(failover, name, attempted)
+	var errs []error
+	if len(stranded) > 0 {
+		if err != nil {
+			errs = append(errs, err)
+		}
+		for _, token := range stranded {
+			if res, ok := failed[token.Token]; ok {
+				kept = append(kept, res)
+			}
+		}
+	}
+
+	for _, fallback := range next {
+		p.metrics.IncFallback()
+		p.logger.Warn("falling back to next provider",
+			slog.String("request_id", envelope.RequestID),
+			slog.String("from", name),
+			slog.String("to", fallback.provider.Name()),
+			slog.Int("tokens", len(fallback.tokens)))
+		res, fbErr := p.sendBatch(ctx, envelope, fallback, payload, attempted)
+		kept = append(kept, res...)
+		if fbErr != nil {
+			errs = append(errs, fbErr)
+		}
+	}
+	return kept, errors.Join(errs...)
 }
 
-func (p *PushProcessor) filterTokens(ctx context.Context, tokens []models.PushToken) ([]models.PushToken, error) {
+// filterTokens drops empty and suppressed tokens, and tokens whose type
+// cannot receive kind.
+func (p *PushProcessor) filterTokens(ctx context.Context, tokens []models.PushToken, kind MessageKind) ([]models.PushToken, error) {
 	if len(tokens) == 0 {
 		return nil, nil
 	}
 	filtered := make([]models.PushToken, 0, len(tokens))
 	for _, token := range tokens {
-		if token.Token == "" {
-			continue
-		}
-		if !supportsFCM(token.Platform) {
+		if token.Token == "" || !kind.eligible(token) {
 			continue
 		}
 		if p.cache != nil {
@@ -128,46 +396,232 @@ func (p *PushProcessor) filterTokens(ctx context.Context, tokens []models.PushTo
 	return filtered, nil
 }
 
-func (p *PushProcessor) handleResults(ctx context.Context, envelope *models.MessageEnvelope, results []models.PushResult) error {
-	if len(results) == 0 {
-		return fmt.Errorf("fcm returned no results")
+// suppressFatalTokens marks tokens their home provider rejected permanently.
+// A fallback provider rejecting a token it took over proves nothing about it.
+func (p *PushProcessor) suppressFatalTokens(ctx context.Context, tokens []models.PushToken, results []models.PushResult) {
+	if p.cache == nil {
+		return
+	}
+	byToken := make(map[string]models.PushToken, len(tokens))
+	for _, token := range tokens {
+		byToken[token.Token] = token
 	}
-
-	var failures []string
 	for _, res := range results {
-		if res.Status == models.ResultDelivered {
+		if res.Status == models.ResultDelivered || ClassifyErrorCode(res.Error) != ErrorTokenFatal {
 			continue
 		}
-		failures = append(failures, fmt.Sprintf("%s:%s", res.Token, res.Error))
-		if p.cache != nil && isTokenFatal(res.Error) {
+		if token, ok := byToken[res.Token]; ok && p.providers.IsHome(token, res.Provider) {
 			_ = p.cache.SuppressToken(ctx, res.Token, 0)
 		}
 	}
+}
 
-	if len(failures) > 0 {
-		return fmt.Errorf("failed tokens: %s", strings.Join(failures, ", "))
+// messageFatalError returns a message-fatal error when any token was rejected
+// because of the message itself, so retries stop immediately.
+func messageFatalError(results []models.PushResult) error {
+	for _, res := range results {
+		if res.Status != models.ResultDelivered && ClassifyErrorCode(res.Error) == ErrorMessageFatal {
+			return &ProviderError{
+				Provider: res.Provider,
+				Code:     res.Error,
+				Class:    ErrorMessageFatal,
+				Err:      fmt.Errorf("message rejected: %s", res.Error),
+			}
+		}
 	}
-
-	p.statusUpdater.MarkDelivered(ctx, envelope.RequestID, p.fcm.Name())
 	return nil
 }
 
-func supportsFCM(platform string) bool {
-	switch strings.ToLower(platform) {
-	case "android", "ios":
-		return true
-	default:
-		return false
+// handleRotations suppresses tokens the provider replaced with a canonical ID
+// and publishes the old -> new mapping for the user service.
+func (p *PushProcessor) handleRotations(ctx context.Context, envelope *models.MessageEnvelope, results []models.PushResult) {
+	for _, res := range results {
+		if res.CanonicalToken == "" || res.CanonicalToken == res.Token {
+			continue
+		}
+		p.logger.Info("device token rotated", slog.String("request_id", envelope.RequestID),
+			slog.String("user_id", envelope.User.ID), slog.String("provider", res.Provider))
+
+		if p.cache != nil {
+			if err := p.cache.SuppressToken(ctx, res.Token, 0); err != nil {
+				p.logger.Warn("failed to suppress rotated token", slog.Any("error", err))
+			}
+		}
+		if p.tokenEvents == nil {
+			continue
+		}
+		event := models.TokenRotatedEvent{
+			UserID:    envelope.User.ID,
+			OldToken:  res.Token,
+			NewToken:  res.CanonicalToken,
+			Platform:  platformForToken(envelope.User.PushTokens, res.Token),
+			Provider:  res.Provider,
+			RequestID: envelope.RequestID,
+			RotatedAt: time.Now().UTC(),
+		}
+		if err := p.tokenEvents.PublishTokenRotated(ctx, event); err != nil {
+			p.logger.Error("failed to publish token rotation", slog.String("request_id", envelope.RequestID), slog.Any("error", err))
+		}
 	}
 }
 
-func isTokenFatal(err string) bool {
-	switch err {
-	case "NotRegistered", "InvalidRegistration", "MismatchSenderId", "MessageTooBig":
+func supportsDryRun(provider PushProvider) bool {
+	validator, ok := provider.(DryRunValidator)
+	return ok && validator.SupportsDryRun()
+}
+
+// supportsKind reports whether provider can send kind. Alert, data and
+// background messages are supported everywhere.
+func supportsKind(provider PushProvider, kind MessageKind) bool {
+	switch kind {
+	case KindAlert, KindData, KindBackground, "":
 		return true
+	}
+	supporter, ok := provider.(KindSupporter)
+	return ok && supporter.SupportsKind(kind)
+}
+
+// failedResults fails every token with code without calling the provider.
+func failedResults(provider string, tokens []models.PushToken, code string) []models.PushResult {
+	results := make([]models.PushResult, 0, len(tokens))
+	for _, token := range tokens {
+		results = append(results, models.PushResult{
+			Token:    token.Token,
+			Provider: provider,
+			Status:   models.ResultFailed,
+			Error:    code,
+		})
+	}
+	return results
+}
+
+// dryRunResults stands in for a provider that cannot validate without
+// delivering; the payload was fully built, so the tokens count as validated.
+func dryRunResults(provider string, tokens []models.PushToken) []models.PushResult {
+	results := make([]models.PushResult, 0, len(tokens))
+	for _, token := range tokens {
+		results = append(results, models.PushResult{
+			Token:    token.Token,
+			Provider: provider,
+			Status:   models.ResultDelivered,
+		})
+	}
+	return results
+}
+
+// deliveryOptions validates the envelope kind, priority, TTL and Live
+// Activity. Invalid values are message-fatal since no provider would accept
+// them.
+func deliveryOptions(envelope *models.MessageEnvelope) (MessageKind, Priority, error) {
+	kind, err := ParseMessageKind(envelope.Kind)
+	if err != nil {
+		return "", "", &ProviderError{Code: "InvalidMessageKind", Class: ErrorMessageFatal, Err: err}
+	}
+	priority, err := ParsePriority(envelope.Priority)
+	if err != nil {
+		return "", "", &ProviderError{Code: "InvalidPriority", Class: ErrorMessageFatal, Err: err}
+	}
+	if envelope.TTLSeconds < 0 {
+		return "", "", &ProviderError{Code: "InvalidTtl", Class: ErrorMessageFatal,
+			Err: fmt.Errorf("negative ttl_seconds %d", envelope.TTLSeconds)}
+	}
+	if kind == KindLiveActivity {
+		if err := validateLiveActivity(envelope.LiveActivity); err != nil {
+			return "", "", &ProviderError{Code: "InvalidLiveActivity", Class: ErrorMessageFatal, Err: err}
+		}
+	}
+	return kind, priority, nil
+}
+
+func validateLiveActivity(activity *models.LiveActivity) error {
+	if activity == nil {
+		return errors.New("live_activity is required for liveactivity messages")
+	}
+	switch activity.Event {
+	case models.LiveActivityStart:
+		if activity.AttributesType == "" || activity.Attributes == nil {
+			return errors.New("attributes_type and attributes are required to start a live activity")
+		}
+	case models.LiveActivityUpdate, models.LiveActivityEnd:
 	default:
-		return false
+		return fmt.Errorf("unknown live activity event %q", activity.Event)
+	}
+	if activity.ContentState == nil {
+		return errors.New("live activity content_state is required")
+	}
+	return nil
+}
+
+// render renders the title, body and notification options of tpl with the
+// envelope variables. The slug stands in for a missing subject.
+func (p *PushProcessor) render(tpl *models.Template, envelope *models.MessageEnvelope) (string, string, models.NotificationOptions, error) {
+	titleTemplate := tpl.Subject
+	if titleTemplate == "" {
+		titleTemplate = tpl.Slug
+	}
+	title, err := p.renderer.Render(tpl, "subject", titleTemplate, envelope.Variables)
+	if err != nil {
+		return "", "", models.NotificationOptions{}, err
+	}
+	body, err := p.renderer.Render(tpl, "body", tpl.Body, envelope.Variables)
+	if err != nil {
+		return "", "", models.NotificationOptions{}, err
+	}
+	opts, err := p.notificationOptions(tpl, envelope)
+	if err != nil {
+		return "", "", models.NotificationOptions{}, err
+	}
+	return title, body, opts, nil
+}
+
+// notificationOptions merges the template presentation defaults with the
+// envelope's and renders the text fields with the envelope variables.
+func (p *PushProcessor) notificationOptions(tpl *models.Template, envelope *models.MessageEnvelope) (models.NotificationOptions, error) {
+	var opts models.NotificationOptions
+	if tpl.Notification != nil {
+		opts = *tpl.Notification
+	}
+	opts = opts.Merge(envelope.Notification)
+
+	var err error
+	if opts.ImageURL, err = p.renderer.Render(tpl, "image_url", opts.ImageURL, envelope.Variables); err != nil {
+		return opts, err
+	}
+	if opts.ClickAction, err = p.renderer.Render(tpl, "click_action", opts.ClickAction, envelope.Variables); err != nil {
+		return opts, err
+	}
+	if len(opts.Actions) > 0 {
+		actions := make([]models.NotificationAction, len(opts.Actions))
+		for i, action := range opts.Actions {
+			field := "actions." + strconv.Itoa(i)
+			if action.Title, err = p.renderer.Render(tpl, field+".title", action.Title, envelope.Variables); err != nil {
+				return opts, err
+			}
+			if action.URL, err = p.renderer.Render(tpl, field+".url", action.URL, envelope.Variables); err != nil {
+				return opts, err
+			}
+			actions[i] = action
+		}
+		opts.Actions = actions
+	}
+	return opts, nil
+}
+
+func platformForToken(tokens []models.PushToken, value string) string {
+	for _, token := range tokens {
+		if token.Token == value {
+			return token.Platform
+		}
+	}
+	return ""
+}
+
+func routedTokens(batches []providerBatch) []models.PushToken {
+	var tokens []models.PushToken
+	for _, batch := range batches {
+		tokens = append(tokens, batch.tokens...)
 	}
+	return tokens
 }
 
 func toStringMap(vars map[string]interface{}) map[string]string {
@@ -0,0 +1,222 @@
+package services
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CyberwizD/Distributed-Notification-System/services/push_service/internal/models"
+	"github.com/CyberwizD/Distributed-Notification-System/services/push_service/pkg/metrics"
+	"github.com/CyberwizD/Distributed-Notification-System/services/push_service/pkg/retry"
+)
+
+// memoryStatusStore keeps the latest status per request in memory.
+type memoryStatusStore struct {
+	mu       sync.Mutex
+	statuses map[string]string
+}
+
+func (s *memoryStatusStore) UpdateStatus(_ context.Context, requestID, status, _, _ string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.statuses[requestID] = status
+	return nil
+}
+
+func (s *memoryStatusStore) UpdateProcessing(ctx context.Context, requestID, status string, _ int) error {
+	return s.UpdateStatus(ctx, requestID, status, "", "")
+}
+
+func (s *memoryStatusStore) status(requestID string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.statuses[requestID]
+}
+
+// newMockProcessor returns a processor that sends every android token through
+// a MockProvider and keeps statuses in memory.
+func newMockProcessor(t *testing.T) (*PushProcessor, *MockProvider, *memoryStatusStore) {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	mock := NewMockProvider(nil, logger)
+	registry := NewProviderRegistry()
+	registry.Register(mock, "android")
+	store := &memoryStatusStore{statuses: make(map[string]string)}
+	processor := NewPushProcessor(nil, registry, NewStatusUpdater(store, logger), nil, nil, metrics.New(), logger,
+		retry.Config{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond},
+		false, TruncationPolicy{}, InlineTemplatePolicy{})
+	return processor, mock, store
+}
+
+// testEnvelope builds an inline template push to the given android tokens.
+func testEnvelope(requestID string, tokens ...string) *models.MessageEnvelope {
+	envelope := &models.MessageEnvelope{
+		RequestID: requestID,
+		Channel:   "push",
+		Template:  models.Template{Subject: "Hi {{name}}", Body: "Your order shipped"},
+		Variables: map[string]interface{}{"name": "Ada"},
+	}
+	for _, token := range tokens {
+		envelope.User.PushTokens = append(envelope.User.PushTokens, models.PushToken{Token: token, Platform: "android"})
+	}
+	return envelope
+}
+
+func TestPushProcessorProcess(t *testing.T) {
+	tests := []struct {
+		name       string
+		script     map[string]string
+		dryRun     bool
+		wantStatus string
+		// wantClass is checked only when Process fails.
+		wantErr   bool
+		wantClass ErrorClass
+		// wantSends lists the tokens of each payload the mock received.
+		wantSends [][]string
+	}{
+		{
+			name:       "delivered",
+			wantStatus: StatusDelivered,
+			wantSends:  [][]string{{"a", "b"}},
+		},
+		{
+			name:       "partial delivery is acknowledged",
+			script:     map[string]string{"b": "NotRegistered"},
+			wantStatus: StatusPartiallyDelivered,
+			wantSends:  [][]string{{"a", "b"}},
+		},
+		{
+			name:       "token-fatal failure is dead-lettered",
+			script:     map[string]string{"a": "NotRegistered", "b": "InvalidRegistration"},
+			wantStatus: StatusFailed,
+			wantErr:    true,
+			wantClass:  ErrorTokenFatal,
+			wantSends:  [][]string{{"a", "b"}},
+		},
+		{
+			name:       "message-fatal failure stops retries",
+			script:     map[string]string{mockAnyToken: "MessageTooBig"},
+			wantStatus: StatusFailed,
+			wantErr:    true,
+			wantClass:  ErrorMessageFatal,
+			wantSends:  [][]string{{"a", "b"}},
+		},
+		{
+			name:       "retries resend only pending tokens",
+			script:     map[string]string{"b": "QuotaExceeded"},
+			wantStatus: StatusPartiallyDelivered,
+			wantSends:  [][]string{{"a", "b"}, {"b"}, {"b"}},
+		},
+		{
+			name:       "retryable failure of every token is requeued",
+			script:     map[string]string{mockAnyToken: "QuotaExceeded"},
+			wantStatus: StatusFailed,
+			wantErr:    true,
+			wantClass:  ErrorRetryable,
+			wantSends:  [][]string{{"a", "b"}, {"a", "b"}, {"a", "b"}},
+		},
+		{
+			name:       "dry run",
+			dryRun:     true,
+			wantStatus: StatusDryRun,
+			wantSends:  [][]string{{"a", "b"}},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			processor, mock, store := newMockProcessor(t)
+			for token, code := range tc.script {
+				mock.Script(token, code)
+			}
+			envelope := testEnvelope("req-1", "a", "b")
+			envelope.DryRun = tc.dryRun
+
+			err := processor.Process(context.Background(), envelope, "")
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("Process succeeded, want an error")
+				}
+				if got := ClassOf(err); got != tc.wantClass {
+					t.Errorf("error class = %s, want %s (%v)", got, tc.wantClass, err)
+				}
+			} else if err != nil {
+				t.Fatalf("Process: %v", err)
+			}
+			if got := store.status("req-1"); got != tc.wantStatus {
+				t.Errorf("status = %q, want %q", got, tc.wantStatus)
+			}
+
+			sends := mock.Sends()
+			if len(sends) != len(tc.wantSends) {
+				t.Fatalf("mock received %d sends, want %d", len(sends), len(tc.wantSends))
+			}
+			for i, send := range sends {
+				if got := pushTokenValues(send.Tokens); !slices.Equal(got, tc.wantSends[i]) {
+					t.Errorf("send %d tokens = %v, want %v", i, got, tc.wantSends[i])
+				}
+				if send.DryRun != tc.dryRun {
+					t.Errorf("send %d DryRun = %v, want %v", i, send.DryRun, tc.dryRun)
+				}
+				if send.Title != "Hi Ada" {
+					t.Errorf("send %d title = %q, want the rendered template", i, send.Title)
+				}
+			}
+		})
+	}
+}
+
+func pushTokenValues(tokens []models.PushToken) []string {
+	values := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		values = append(values, token.Token)
+	}
+	return values
+}
+
+// TestMockProviderScripting drives the processor through the mock's script,
+// clear and reset controls between messages.
+func TestMockProviderScripting(t *testing.T) {
+	processor, mock, store := newMockProcessor(t)
+	ctx := context.Background()
+
+	mock.Script("b", "NotRegistered")
+	if err := processor.Process(ctx, testEnvelope("req-1", "a", "b"), ""); err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if got := store.status("req-1"); got != StatusPartiallyDelivered {
+		t.Errorf("status = %q, want %q", got, StatusPartiallyDelivered)
+	}
+
+	mock.Script("b", "")
+	mock.Script(mockAnyToken, "InvalidRegistration")
+	if err := processor.Process(ctx, testEnvelope("req-2", "a", "b"), ""); ClassOf(err) != ErrorTokenFatal {
+		t.Errorf("Process error = %v, want token-fatal", err)
+	}
+	if got := len(mock.Sends()); got != 2 {
+		t.Errorf("mock recorded %d sends, want 2", got)
+	}
+
+	mock.Reset()
+	if got := len(mock.Sends()); got != 0 {
+		t.Errorf("mock recorded %d sends after Reset, want 0", got)
+	}
+	if err := processor.Process(ctx, testEnvelope("req-3", "a", "b"), ""); err != nil {
+		t.Fatalf("Process after Reset: %v", err)
+	}
+	if got := store.status("req-3"); got != StatusDelivered {
+		t.Errorf("status after Reset = %q, want %q", got, StatusDelivered)
+	}
+
+	for i := 0; i < mockMaxSends+10; i++ {
+		if _, err := mock.Send(ctx, &PushPayload{Tokens: []models.PushToken{{Token: "a"}}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := len(mock.Sends()); got != mockMaxSends {
+		t.Errorf("mock recorded %d sends, want at most %d", got, mockMaxSends)
+	}
+}
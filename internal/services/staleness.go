@@ -0,0 +1,35 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/CyberwizD/Distributed-Notification-System/services/push_service/internal/models"
+)
+
+// StalenessPolicy decides whether an envelope is too old to be worth sending,
+// e.g. when a backlog drains after an outage.
+type StalenessPolicy struct {
+	// MaxAge is the default maximum age since CreatedAt; zero disables it.
+	MaxAge time.Duration
+}
+
+// Expired reports whether envelope is stale at now, with the reason. The
+// envelope's expires_at and max_age take precedence over the default.
+func (p StalenessPolicy) Expired(envelope *models.MessageEnvelope, now time.Time) (string, bool) {
+	if envelope.ExpiresAt != nil && !now.Before(*envelope.ExpiresAt) {
+		return fmt.Sprintf("expired at %s", envelope.ExpiresAt.UTC().Format(time.RFC3339)), true
+	}
+
+	maxAge := p.MaxAge
+	if envelope.MaxAge > 0 {
+		maxAge = time.Duration(envelope.MaxAge) * time.Second
+	}
+	if maxAge <= 0 || envelope.CreatedAt.IsZero() {
+		return "", false
+	}
+	if age := now.Sub(envelope.CreatedAt); age > maxAge {
+		return fmt.Sprintf("message is %s old, max age %s", age.Truncate(time.Second), maxAge), true
+	}
+	return "", false
+}
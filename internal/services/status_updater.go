@@ -4,30 +4,38 @@ import (
 	"context"
 
 	"log/slog"
-
-	"github.com/CyberwizD/Distributed-Notification-System/services/push_service/internal/repository"
 )
 
 const (
-	StatusProcessing = "processing"
-	StatusDelivered  = "delivered"
-	StatusFailed     = "failed"
+	StatusProcessing         = "processing"
+	StatusDelivered          = "delivered"
+	StatusPartiallyDelivered = "partially_delivered"
+	StatusFailed             = "failed"
+	StatusDryRun             = "dry_run"
+	StatusExpired            = "expired"
 )
 
+// StatusStore persists notification statuses; repository.StatusStore is the
+// Postgres implementation.
+type StatusStore interface {
+	UpdateStatus(ctx context.Context, requestID, status, provider, detail string) error
+	UpdateProcessing(ctx context.Context, requestID, status string, templateVersion int) error
+}
+
 type StatusUpdater struct {
-	store  *repository.StatusStore
+	store  StatusStore
 	logger *slog.Logger
 }
 
-func NewStatusUpdater(store *repository.StatusStore, logger *slog.Logger) *StatusUpdater {
+func NewStatusUpdater(store StatusStore, logger *slog.Logger) *StatusUpdater {
 	return &StatusUpdater{
 		store:  store,
 		logger: logger,
 	}
 }
 
-func (s *StatusUpdater) MarkProcessing(ctx context.Context, requestID string) {
-	if err := s.store.UpdateStatus(ctx, requestID, StatusProcessing, "", ""); err != nil {
+func (s *StatusUpdater) MarkProcessing(ctx context.Context, requestID string, templateVersion int) {
+	if err := s.store.UpdateProcessing(ctx, requestID, StatusProcessing, templateVersion); err != nil {
 		s.logger.Error("failed to update processing status", slog.String("request_id", requestID), slog.Any("error", err))
 	}
 }
@@ -38,8 +46,26 @@ func (s *StatusUpdater) MarkDelivered(ctx context.Context, requestID, provider s
 	}
 }
 
+func (s *StatusUpdater) MarkPartiallyDelivered(ctx context.Context, requestID, provider, detail string) {
+	if err := s.store.UpdateStatus(ctx, requestID, StatusPartiallyDelivered, provider, detail); err != nil {
+		s.logger.Error("failed to update partially delivered status", slog.String("request_id", requestID), slog.Any("error", err))
+	}
+}
+
 func (s *StatusUpdater) MarkFailed(ctx context.Context, requestID, provider, detail string) {
 	if err := s.store.UpdateStatus(ctx, requestID, StatusFailed, provider, detail); err != nil {
 		s.logger.Error("failed to update failed status", slog.String("request_id", requestID), slog.Any("error", err))
 	}
 }
+
+func (s *StatusUpdater) MarkDryRun(ctx context.Context, requestID, provider, detail string) {
+	if err := s.store.UpdateStatus(ctx, requestID, StatusDryRun, provider, detail); err != nil {
+		s.logger.Error("failed to update dry run status", slog.String("request_id", requestID), slog.Any("error", err))
+	}
+}
+
+func (s *StatusUpdater) MarkExpired(ctx context.Context, requestID, detail string) {
+	if err := s.store.UpdateStatus(ctx, requestID, StatusExpired, "", detail); err != nil {
+		s.logger.Error("failed to update expired status", slog.String("request_id", requestID), slog.Any("error", err))
+	}
+}
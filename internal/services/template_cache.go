@@ -0,0 +1,104 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+
+	"github.com/CyberwizD/Distributed-Notification-System/services/push_service/internal/models"
+)
+
+// TemplateCacheStore is an optional shared cache behind the in-process one,
+// such as Redis, so replicas and restarts reuse each other's fetches.
+type TemplateCacheStore interface {
+	// GetTemplate returns nil and no error on a miss.
+	GetTemplate(ctx context.Context, key string) ([]byte, error)
+	SetTemplate(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+// TemplateCache holds fetched templates keyed by slug, locale and version.
+// Entries are fresh for ttl, after which they are revalidated with their
+// ETag; for a further staleTTL they are still served when the template
+// service is unavailable.
+type TemplateCache struct {
+	ttl      time.Duration
+	staleTTL time.Duration
+	store    TemplateCacheStore
+
+	mu      sync.RWMutex
+	entries map[string]templateEntry
+	group   singleflight.Group
+}
+
+type templateEntry struct {
+	Template  models.Template `json:"template"`
+	ETag      string          `json:"etag,omitempty"`
+	FetchedAt time.Time       `json:"fetched_at"`
+}
+
+func NewTemplateCache(ttl, staleTTL time.Duration, store TemplateCacheStore) *TemplateCache {
+	if ttl <= 0 {
+		ttl = 5 * time.Minute
+	}
+	if staleTTL < 0 {
+		staleTTL = 0
+	}
+	return &TemplateCache{
+		ttl:      ttl,
+		staleTTL: staleTTL,
+		store:    store,
+		entries:  make(map[string]templateEntry),
+	}
+}
+
+func templateCacheKey(slug, locale, version string) string {
+	return slug + ":" + locale + ":" + version
+}
+
+func (c *TemplateCache) fresh(entry templateEntry, now time.Time) bool {
+	return now.Sub(entry.FetchedAt) < c.ttl
+}
+
+// get returns the newest usable entry for key from memory or the store.
+// Store errors are treated as misses.
+func (c *TemplateCache) get(ctx context.Context, key string, now time.Time) (templateEntry, bool) {
+	c.mu.RLock()
+	entry, ok := c.entries[key]
+	c.mu.RUnlock()
+
+	if (!ok || !c.fresh(entry, now)) && c.store != nil {
+		if raw, err := c.store.GetTemplate(ctx, key); err == nil && raw != nil {
+			var stored templateEntry
+			if json.Unmarshal(raw, &stored) == nil && (!ok || stored.FetchedAt.After(entry.FetchedAt)) {
+				entry, ok = stored, true
+				c.mu.Lock()
+				c.entries[key] = stored
+				c.mu.Unlock()
+			}
+		}
+	}
+
+	if !ok || now.Sub(entry.FetchedAt) >= c.ttl+c.staleTTL {
+		return templateEntry{}, false
+	}
+	return entry, true
+}
+
+// put stores entry in memory and, best effort, in the store.
+func (c *TemplateCache) put(ctx context.Context, key string, entry templateEntry) error {
+	c.mu.Lock()
+	c.entries[key] = entry
+	c.mu.Unlock()
+
+	if c.store == nil {
+		return nil
+	}
+	raw, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return c.store.SetTemplate(ctx, key, raw, c.ttl+c.staleTTL)
+}
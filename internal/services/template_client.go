@@ -3,9 +3,12 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"net/url"
+	"strconv"
 	"time"
 
 	"github.com/CyberwizD/Distributed-Notification-System/services/push_service/internal/models"
@@ -20,17 +23,23 @@ type tplResponse struct {
 
 type tplDTO struct {
 	ID      string `json:"id"`
+	Version int    `json:"version"`
 	Subject string `json:"subject"`
 	Body    string `json:"body"`
+	// Metadata carries push presentation defaults such as sound or channel.
+	Metadata *models.NotificationOptions `json:"metadata,omitempty"`
 }
 
-// TemplateClient fetches templates from the template service.
+// TemplateClient fetches templates from the template service, through cache
+// when one is configured.
 type TemplateClient struct {
 	baseURL string
 	client  *http.Client
+	cache   *TemplateCache
+	logger  *slog.Logger
 }
 
-func NewTemplateClient(baseURL string, timeout time.Duration) *TemplateClient {
+func NewTemplateClient(baseURL string, timeout time.Duration, cache *TemplateCache, logger *slog.Logger) *TemplateClient {
 	if timeout <= 0 {
 		timeout = 5 * time.Second
 	}
@@ -39,46 +48,163 @@ func NewTemplateClient(baseURL string, timeout time.Duration) *TemplateClient {
 		client: &http.Client{
 			Timeout: timeout,
 		},
+		cache:  cache,
+		logger: logger,
 	}
 }
 
-func (c *TemplateClient) Fetch(ctx context.Context, slug, locale string) (*models.Template, error) {
+// errTemplateNotModified is returned by fetch for a 304 response.
+var errTemplateNotModified = errors.New("template not modified")
+
+// templateStatusError is an unexpected template service response status.
+type templateStatusError struct {
+	StatusCode int
+}
+
+func (e *templateStatusError) Error() string {
+	return fmt.Sprintf("template service returned %d", e.StatusCode)
+}
+
+// Fetch returns the given version of a template, or the active version when
+// version is zero. The returned Version is the one the service resolved.
+func (c *TemplateClient) Fetch(ctx context.Context, slug, locale string, version int) (*models.Template, error) {
 	if locale == "" {
 		locale = "en"
 	}
-	path := fmt.Sprintf("%s/v1/templates/%s/active?locale=%s",
+	if c.cache == nil {
+		tpl, _, err := c.fetch(ctx, slug, locale, version, "")
+		return tpl, err
+	}
+
+	key := templateCacheKey(slug, locale, templateVersionPath(version))
+	cached, ok := c.cache.get(ctx, key, time.Now())
+	if ok && c.cache.fresh(cached, time.Now()) {
+		tpl := cached.Template
+		return &tpl, nil
+	}
+
+	// Concurrent misses share one request, which outlives any single caller.
+	shared := context.WithoutCancel(ctx)
+	ch := c.cache.group.DoChan(key, func() (interface{}, error) {
+		return c.revalidate(shared, key, slug, locale, version)
+	})
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		tpl := res.Val.(models.Template)
+		return &tpl, nil
+	}
+}
+
+// revalidate refreshes key with a conditional request, falling back to the
+// stale entry when the template service is unavailable.
+func (c *TemplateClient) revalidate(ctx context.Context, key, slug, locale string, version int) (models.Template, error) {
+	now := time.Now()
+	cached, ok := c.cache.get(ctx, key, now)
+	if ok && c.cache.fresh(cached, now) {
+		return cached.Template, nil
+	}
+
+	var etag string
+	if ok {
+		etag = cached.ETag
+	}
+	tpl, newETag, err := c.fetch(ctx, slug, locale, version, etag)
+
+	var entry templateEntry
+	switch {
+	case ok && errors.Is(err, errTemplateNotModified):
+		entry = cached
+		entry.FetchedAt = now
+	case err != nil:
+		if ok && templateUnavailable(err) {
+			c.logger.Warn("template service unavailable, serving stale template",
+				slog.String("slug", slug), slog.String("locale", locale),
+				slog.Int("template_version", cached.Template.Version), slog.Duration("age", now.Sub(cached.FetchedAt)), slog.Any("error", err))
+			return cached.Template, nil
+		}
+		return models.Template{}, err
+	default:
+		entry = templateEntry{Template: *tpl, ETag: newETag, FetchedAt: now}
+	}
+
+	if err := c.cache.put(ctx, key, entry); err != nil {
+		c.logger.Warn("failed to store template in cache", slog.String("slug", slug), slog.Any("error", err))
+	}
+	return entry.Template, nil
+}
+
+// templateUnavailable reports whether err means the template service could
+// not answer, as opposed to answering that the template is missing or invalid.
+func templateUnavailable(err error) bool {
+	var statusErr *templateStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500 || statusErr.StatusCode == http.StatusTooManyRequests
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+// templateVersionPath is the path segment selecting version, "active" for zero.
+func templateVersionPath(version int) string {
+	if version == 0 {
+		return "active"
+	}
+	return "versions/" + strconv.Itoa(version)
+}
+
+// fetch requests a template version, conditionally on etag when it is set.
+// It returns the response ETag alongside the template.
+func (c *TemplateClient) fetch(ctx context.Context, slug, locale string, version int, etag string) (*models.Template, string, error) {
+	path := fmt.Sprintf("%s/v1/templates/%s/%s?locale=%s",
 		c.baseURL,
 		url.PathEscape(slug),
+		templateVersionPath(version),
 		url.QueryEscape(locale),
 	)
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, path, nil)
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
 	}
 
 	resp, err := c.client.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotModified && etag != "" {
+		return nil, "", errTemplateNotModified
+	}
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("template service returned %d", resp.StatusCode)
+		return nil, "", &templateStatusError{StatusCode: resp.StatusCode}
 	}
 
 	var envelope tplResponse
 	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	if !envelope.Success || envelope.Data == nil {
-		return nil, fmt.Errorf("template service error: %s", envelope.Message)
+		return nil, "", fmt.Errorf("template service error: %s", envelope.Message)
 	}
 
+	resolved := envelope.Data.Version
+	if resolved == 0 {
+		resolved = version
+	}
 	return &models.Template{
-		Slug:    slug,
-		Locale:  locale,
-		Version: 0,
-		Subject: envelope.Data.Subject,
-		Body:    envelope.Data.Body,
-	}, nil
+		Slug:         slug,
+		Locale:       locale,
+		Version:      resolved,
+		Subject:      envelope.Data.Subject,
+		Body:         envelope.Data.Body,
+		Notification: envelope.Data.Metadata,
+	}, resp.Header.Get("ETag"), nil
 }
@@ -0,0 +1,576 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The template engine implements the Handlebars subset push templates need:
+//
+//	{{user.first_name}}           dotted paths, "items.0.name", "items.length"
+//	{{#if x}}..{{else}}..{{/if}}  conditionals, also {{#unless}}
+//	{{#each items}}..{{/each}}    loops with this, @index, @key, @first, @last
+//	{{default nickname "there"}}  helpers, see templateHelpers
+//	{{! comment }}, {{~ trims whitespace ~}}
+//
+// It is sandboxed: templates only read the variables they are given, and
+// nesting, loop iterations and output size are bounded. Output is plain text, so nothing is
+// HTML escaped and {{{x}}} is the same as {{x}}. Missing values render empty.
+
+const (
+	maxTemplateDepth  = 32
+	maxTemplateOutput = 64 << 10
+	// maxTemplateSteps caps {{#each}} iterations across a render, since nested
+	// loops that write nothing never reach the output limit.
+	maxTemplateSteps = 10000
+)
+
+var (
+	errTemplateTooLarge = fmt.Errorf("template output exceeds %d bytes", maxTemplateOutput)
+	errTemplateTooLong  = fmt.Errorf("template loops exceed %d iterations", maxTemplateSteps)
+)
+
+type tplNode interface{}
+
+type tplText string
+
+// tplExpr prints a value, or the result of helper applied to args.
+type tplExpr struct {
+	helper string
+	args   []tplArg
+}
+
+type tplBlock struct {
+	name    string
+	arg     tplArg
+	body    []tplNode
+	inverse []tplNode
+}
+
+// tplArg is a literal or a path. A path climbs up frames first ("../"), and
+// is resolved only in the current frame when it starts with "this".
+type tplArg struct {
+	literal interface{}
+	isPath  bool
+	path    []string
+	up      int
+	local   bool
+}
+
+type compiledTemplate struct {
+	nodes []tplNode
+}
+
+func compileTemplate(src string) (*compiledTemplate, error) {
+	tokens, err := lexTemplate(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &tplParser{tokens: tokens}
+	nodes, _, err := p.parse("")
+	if err != nil {
+		return nil, err
+	}
+	return &compiledTemplate{nodes: nodes}, nil
+}
+
+type tplToken struct {
+	text  string
+	isTag bool
+}
+
+// lexTemplate splits src into text and tag tokens, applying "~" whitespace
+// control. Tag tokens hold the trimmed content between the braces.
+func lexTemplate(src string) ([]tplToken, error) {
+	var tokens []tplToken
+	trimNext := false
+	addText := func(text string) {
+		if trimNext {
+			text = strings.TrimLeft(text, " \t\r\n")
+			trimNext = false
+		}
+		if text != "" {
+			tokens = append(tokens, tplToken{text: text})
+		}
+	}
+
+	i := 0
+	for i < len(src) {
+		start := strings.Index(src[i:], "{{")
+		if start < 0 {
+			addText(src[i:])
+			break
+		}
+		start += i
+		addText(src[i:start])
+
+		open, closing := "{{", "}}"
+		if strings.HasPrefix(src[start:], "{{{") {
+			open, closing = "{{{", "}}}"
+		}
+		body := start + len(open)
+		inner := body
+		if inner < len(src) && src[inner] == '~' {
+			inner++
+		}
+		if strings.HasPrefix(src[inner:], "!--") {
+			closing = "--}}"
+		}
+		end := strings.Index(src[inner:], closing)
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed tag at offset %d", start)
+		}
+		end += inner
+		content := src[body:end]
+		i = end + len(closing)
+
+		if strings.HasPrefix(content, "~") {
+			content = content[1:]
+			if n := len(tokens); n > 0 && !tokens[n-1].isTag {
+				tokens[n-1].text = strings.TrimRight(tokens[n-1].text, " \t\r\n")
+			}
+		}
+		if strings.HasSuffix(content, "~") {
+			content = content[:len(content)-1]
+			trimNext = true
+		}
+		tokens = append(tokens, tplToken{text: strings.TrimSpace(content), isTag: true})
+	}
+	return tokens, nil
+}
+
+type tplParser struct {
+	tokens []tplToken
+	pos    int
+	depth  int
+}
+
+// parse reads nodes up to the {{else}} or closing tag of block, returning
+// which of the two stopped it.
+func (p *tplParser) parse(block string) ([]tplNode, string, error) {
+	var nodes []tplNode
+	for p.pos < len(p.tokens) {
+		token := p.tokens[p.pos]
+		p.pos++
+		if !token.isTag {
+			nodes = append(nodes, tplText(token.text))
+			continue
+		}
+
+		tag := token.text
+		switch {
+		case strings.HasPrefix(tag, "!"):
+		case tag == "else" || tag == "^":
+			if block == "" {
+				return nil, "", errors.New("{{else}} outside a block")
+			}
+			return nodes, "else", nil
+		case strings.HasPrefix(tag, "/"):
+			name := strings.TrimSpace(tag[1:])
+			if name != block {
+				return nil, "", fmt.Errorf("unexpected {{/%s}}", name)
+			}
+			return nodes, "/", nil
+		case strings.HasPrefix(tag, "#"):
+			node, err := p.parseBlock(tag[1:])
+			if err != nil {
+				return nil, "", err
+			}
+			nodes = append(nodes, node)
+		default:
+			node, err := parseExpr(tag)
+			if err != nil {
+				return nil, "", err
+			}
+			nodes = append(nodes, node)
+		}
+	}
+	if block != "" {
+		return nil, "", fmt.Errorf("unclosed {{#%s}}", block)
+	}
+	return nodes, "", nil
+}
+
+func (p *tplParser) parseBlock(tag string) (tplNode, error) {
+	fields, err := splitTemplateArgs(tag)
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, errors.New("empty block tag")
+	}
+	name := fields[0]
+	switch name {
+	case "if", "unless", "each":
+	default:
+		return nil, fmt.Errorf("unknown block {{#%s}}", name)
+	}
+	if len(fields) != 2 {
+		return nil, fmt.Errorf("{{#%s}} takes one argument", name)
+	}
+	arg, err := parseTemplateArg(fields[1])
+	if err != nil {
+		return nil, err
+	}
+
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxTemplateDepth {
+		return nil, fmt.Errorf("blocks nested deeper than %d", maxTemplateDepth)
+	}
+
+	block := tplBlock{name: name, arg: arg}
+	var stop string
+	if block.body, stop, err = p.parse(name); err != nil {
+		return nil, err
+	}
+	if stop == "else" {
+		if block.inverse, stop, err = p.parse(name); err != nil {
+			return nil, err
+		}
+		if stop == "else" {
+			return nil, fmt.Errorf("more than one {{else}} in {{#%s}}", name)
+		}
+	}
+	return block, nil
+}
+
+func parseExpr(tag string) (tplNode, error) {
+	fields, err := splitTemplateArgs(tag)
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, errors.New("empty tag")
+	}
+
+	var expr tplExpr
+	if len(fields) > 1 {
+		helper, ok := templateHelpers[fields[0]]
+		if !ok {
+			return nil, fmt.Errorf("unknown helper %q", fields[0])
+		}
+		if n := len(fields) - 1; n < helper.minArgs || n > helper.maxArgs {
+			return nil, fmt.Errorf("helper %q takes %d to %d arguments", fields[0], helper.minArgs, helper.maxArgs)
+		}
+		expr.helper = fields[0]
+		fields = fields[1:]
+	}
+	for _, field := range fields {
+		arg, err := parseTemplateArg(field)
+		if err != nil {
+			return nil, err
+		}
+		expr.args = append(expr.args, arg)
+	}
+	return expr, nil
+}
+
+// splitTemplateArgs splits a tag on whitespace, keeping quoted strings whole.
+func splitTemplateArgs(s string) ([]string, error) {
+	var fields []string
+	for {
+		s = strings.TrimLeft(s, " \t\r\n")
+		if s == "" {
+			return fields, nil
+		}
+		end := strings.IndexAny(s, " \t\r\n")
+		if quote := s[0]; quote == '"' || quote == '\'' {
+			end = -1
+			for i := 1; i < len(s); i++ {
+				if s[i] == '\\' {
+					i++
+					continue
+				}
+				if s[i] == quote {
+					end = i + 1
+					break
+				}
+			}
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string %s", s)
+			}
+		}
+		if end < 0 {
+			end = len(s)
+		}
+		fields = append(fields, s[:end])
+		s = s[end:]
+	}
+}
+
+func parseTemplateArg(field string) (tplArg, error) {
+	switch field {
+	case "true":
+		return tplArg{literal: true}, nil
+	case "false":
+		return tplArg{literal: false}, nil
+	case "null":
+		return tplArg{}, nil
+	}
+	switch field[0] {
+	case '"':
+		value, err := strconv.Unquote(field)
+		if err != nil {
+			return tplArg{}, fmt.Errorf("invalid string %s", field)
+		}
+		return tplArg{literal: value}, nil
+	case '\'':
+		return tplArg{literal: strings.ReplaceAll(field[1:len(field)-1], `\'`, "'")}, nil
+	}
+	if c := field[0]; c == '-' || (c >= '0' && c <= '9') {
+		if number, err := strconv.ParseFloat(field, 64); err == nil {
+			return tplArg{literal: number}, nil
+		}
+	}
+
+	arg := tplArg{isPath: true}
+	for strings.HasPrefix(field, "../") {
+		arg.up++
+		field = field[3:]
+	}
+	switch {
+	case field == "this" || field == ".":
+		arg.local = true
+		return arg, nil
+	case strings.HasPrefix(field, "this."):
+		arg.local = true
+		field = strings.TrimPrefix(field, "this.")
+	}
+	arg.path = strings.Split(field, ".")
+	for _, segment := range arg.path {
+		if segment == "" {
+			return tplArg{}, fmt.Errorf("invalid path %q", field)
+		}
+	}
+	return arg, nil
+}
+
+// tplFrame is a rendering scope: the root variables or the current item of
+// an {{#each}}, with its @data variables.
+type tplFrame struct {
+	value interface{}
+	data  map[string]interface{}
+}
+
+type tplRenderer struct {
+	frames []tplFrame
+	out    strings.Builder
+	steps  int
+}
+
+func (t *compiledTemplate) render(variables map[string]interface{}) (string, error) {
+	r := &tplRenderer{frames: []tplFrame{{value: variables}}}
+	if err := r.renderNodes(t.nodes); err != nil {
+		return "", err
+	}
+	return r.out.String(), nil
+}
+
+func (r *tplRenderer) renderNodes(nodes []tplNode) error {
+	for _, node := range nodes {
+		switch n := node.(type) {
+		case tplText:
+			r.out.WriteString(string(n))
+		case tplExpr:
+			value, err := r.eval(n)
+			if err != nil {
+				return err
+			}
+			r.out.WriteString(formatTemplateValue(value))
+		case tplBlock:
+			if err := r.renderBlock(n); err != nil {
+				return err
+			}
+		}
+		if r.out.Len() > maxTemplateOutput {
+			return errTemplateTooLarge
+		}
+	}
+	return nil
+}
+
+func (r *tplRenderer) renderBlock(block tplBlock) error {
+	value := r.lookup(block.arg)
+	switch block.name {
+	case "if":
+		if templateTruthy(value) {
+			return r.renderNodes(block.body)
+		}
+		return r.renderNodes(block.inverse)
+	case "unless":
+		if !templateTruthy(value) {
+			return r.renderNodes(block.body)
+		}
+		return r.renderNodes(block.inverse)
+	}
+
+	switch items := value.(type) {
+	case []interface{}:
+		if len(items) == 0 {
+			break
+		}
+		for i, item := range items {
+			data := map[string]interface{}{"index": i, "first": i == 0, "last": i == len(items)-1}
+			if err := r.renderItem(block.body, item, data); err != nil {
+				return err
+			}
+		}
+		return nil
+	case map[string]interface{}:
+		if len(items) == 0 {
+			break
+		}
+		keys := make([]string, 0, len(items))
+		for key := range items {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for i, key := range keys {
+			data := map[string]interface{}{"key": key, "index": i, "first": i == 0, "last": i == len(keys)-1}
+			if err := r.renderItem(block.body, items[key], data); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return r.renderNodes(block.inverse)
+}
+
+func (r *tplRenderer) renderItem(nodes []tplNode, item interface{}, data map[string]interface{}) error {
+	r.steps++
+	if r.steps > maxTemplateSteps {
+		return errTemplateTooLong
+	}
+	r.frames = append(r.frames, tplFrame{value: item, data: data})
+	err := r.renderNodes(nodes)
+	r.frames = r.frames[:len(r.frames)-1]
+	return err
+}
+
+func (r *tplRenderer) eval(expr tplExpr) (interface{}, error) {
+	if expr.helper == "" {
+		return r.lookup(expr.args[0]), nil
+	}
+	args := make([]interface{}, len(expr.args))
+	for i, arg := range expr.args {
+		args[i] = r.lookup(arg)
+	}
+	value, err := templateHelpers[expr.helper].fn(args)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", expr.helper, err)
+	}
+	return value, nil
+}
+
+// lookup resolves arg. Paths are looked up from the innermost frame outwards,
+// so loop bodies can still reach the root variables.
+func (r *tplRenderer) lookup(arg tplArg) interface{} {
+	if !arg.isPath {
+		return arg.literal
+	}
+	if arg.up >= len(r.frames) {
+		return nil
+	}
+	frames := r.frames[:len(r.frames)-arg.up]
+	current := frames[len(frames)-1]
+	if len(arg.path) == 0 {
+		return current.value
+	}
+	if arg.local {
+		return walkTemplatePath(current.value, arg.path)
+	}
+
+	head := arg.path[0]
+	for i := len(frames) - 1; i >= 0; i-- {
+		var value interface{}
+		var ok bool
+		if strings.HasPrefix(head, "@") {
+			value, ok = frames[i].data[head[1:]]
+		} else {
+			value, ok = templateField(frames[i].value, head)
+		}
+		if ok {
+			return walkTemplatePath(value, arg.path[1:])
+		}
+	}
+	return nil
+}
+
+func walkTemplatePath(value interface{}, path []string) interface{} {
+	for _, segment := range path {
+		var ok bool
+		if value, ok = templateField(value, segment); !ok {
+			return nil
+		}
+	}
+	return value
+}
+
+// templateField reads one path segment. Only decoded JSON values are
+// traversed: maps by key, and lists by index or "length".
+func templateField(value interface{}, key string) (interface{}, bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		field, ok := v[key]
+		return field, ok
+	case map[string]string:
+		field, ok := v[key]
+		return field, ok
+	case []interface{}:
+		if key == "length" {
+			return len(v), true
+		}
+		if i, err := strconv.Atoi(key); err == nil && i >= 0 && i < len(v) {
+			return v[i], true
+		}
+	}
+	return nil, false
+}
+
+// templateTruthy follows Handlebars: false, null, "", 0 and empty lists are
+// false.
+func templateTruthy(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	case float64:
+		return v != 0
+	case int:
+		return v != 0
+	case json.Number:
+		return v.String() != "0"
+	case []interface{}:
+		return len(v) > 0
+	}
+	return true
+}
+
+func formatTemplateValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case map[string]interface{}, []interface{}:
+		if raw, err := json.Marshal(v); err == nil {
+			return string(raw)
+		}
+	}
+	return fmt.Sprint(value)
+}
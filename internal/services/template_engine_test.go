@@ -0,0 +1,214 @@
+package services
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/CyberwizD/Distributed-Notification-System/services/push_service/internal/models"
+)
+
+const testTemplateVariables = `{
+	"name": "Ada",
+	"user": {"first_name": "Ada", "nickname": "", "vip": true},
+	"count": 1234567,
+	"zero": 0,
+	"total": -1234.5,
+	"items": [{"name": "Tea", "qty": 2}, {"name": "Cake", "qty": 1}],
+	"empty": [],
+	"sizes": {"m": 2, "l": 1},
+	"when": "2026-03-05T10:30:00Z",
+	"day": "2026-03-05",
+	"long": "héllo wörld 👍🏽 done"
+}`
+
+func testVariables(t *testing.T) map[string]interface{} {
+	t.Helper()
+	var vars map[string]interface{}
+	if err := json.Unmarshal([]byte(testTemplateVariables), &vars); err != nil {
+		t.Fatal(err)
+	}
+	return vars
+}
+
+func TestTemplateEngine(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		// Paths and values.
+		{"plain text", "Hello", "Hello"},
+		{"flat variable", "Hi {{name}}!", "Hi Ada!"},
+		{"spaces in tag", "Hi {{ name }}!", "Hi Ada!"},
+		{"dotted path", "Hi {{user.first_name}}", "Hi Ada"},
+		{"list index", "{{items.1.name}}", "Cake"},
+		{"list length", "{{items.length}}", "2"},
+		{"large number", "{{count}}", "1234567"},
+		{"triple stash", "{{{name}}}", "Ada"},
+		{"literals", `{{"a"}} {{'b'}} {{3}} {{true}}`, "a b 3 true"},
+		// Missing variables render empty; the old renderer left "{{missing}}".
+		{"missing variable", "Hi {{missing}}!", "Hi !"},
+		{"missing path", "{{user.missing.deeper}}|", "|"},
+		{"path through scalar", "{{name.first}}|", "|"},
+
+		// Conditionals.
+		{"if true", "{{#if user.vip}}VIP{{/if}}", "VIP"},
+		{"if empty string", "{{#if user.nickname}}nick{{else}}none{{/if}}", "none"},
+		{"if zero", "{{#if zero}}yes{{else}}no{{/if}}", "no"},
+		{"if empty list", "{{#if empty}}yes{{else}}no{{/if}}", "no"},
+		{"if missing", "{{#if missing}}yes{{else}}no{{/if}}", "no"},
+		{"unless", "{{#unless user.nickname}}no nickname{{/unless}}", "no nickname"},
+		{"unless else", "{{#unless user.vip}}regular{{else}}vip{{/unless}}", "vip"},
+		{"nested if", "{{#if user.vip}}{{#if zero}}a{{else}}b{{/if}}{{/if}}", "b"},
+
+		// Loops.
+		{"each list", "{{#each items}}{{name}}x{{qty}} {{/each}}", "Teax2 Cakex1 "},
+		{"each data", "{{#each items}}{{@index}}{{#if @first}}F{{/if}}{{#if @last}}L{{/if}};{{/each}}", "0F;1L;"},
+		{"each separator", "{{#each items}}{{name}}{{#unless @last}}, {{/unless}}{{/each}}", "Tea, Cake"},
+		{"each this", "{{#each items}}{{this.name}};{{/each}}", "Tea;Cake;"},
+		{"each map sorted", "{{#each sizes}}{{@key}}={{this}} {{/each}}", "l=1 m=2 "},
+		{"each else", "{{#each empty}}x{{else}}nothing{{/each}}", "nothing"},
+		{"each missing", "{{#each missing}}x{{else}}nothing{{/each}}", "nothing"},
+		{"outer scope lookup", "{{#each items}}{{name}} for {{user.first_name}};{{/each}}", "Tea for Ada;Cake for Ada;"},
+		{"parent path", "{{#each items}}{{../name}};{{/each}}", "Ada;Ada;"},
+		{"this is local", "{{#each items}}{{this.user}}|{{/each}}", "||"},
+
+		// Comments and whitespace control.
+		{"comment", "a{{! note }}b", "ab"},
+		{"long comment", "a{{!-- a }} b --}}c", "ac"},
+		{"trim both", "a  {{~name~}}  b", "aAdab"},
+		{"trim left", "a  {{~name}}  b", "aAda  b"},
+		{"trim block", "{{#each items~}}\n  {{name}}\n{{~/each}}", "TeaCake"},
+
+		// Helpers.
+		{"upper", "{{upper name}}", "ADA"},
+		{"lower", "{{lower 'ABC'}}", "abc"},
+		{"default used", `{{default user.nickname "friend"}}`, "friend"},
+		{"default missing", `{{default missing "friend"}}`, "friend"},
+		{"default unused", `{{default name "friend"}}`, "Ada"},
+		{"truncate", "{{truncate long 13}}", "héllo wörld 👍🏽…"},
+		{"truncate suffix", "{{truncate long 3 '...'}}", "hél..."},
+		{"truncate short", "{{truncate name 10}}", "Ada"},
+		{"currency symbol", `{{currency total "USD"}}`, "-$1,234.50"},
+		{"currency no decimals", "{{currency count 'JPY'}}", "¥1,234,567"},
+		{"currency code", "{{currency 5 'XYZ'}}", "XYZ 5.00"},
+		{"currency plain", "{{currency 1000.004}}", "1,000.00"},
+		{"currency missing", "{{currency missing 'USD'}}|", "|"},
+		{"date default", "{{date when}}", "Mar 5, 2026"},
+		{"date named", "{{date when 'long'}}", "March 5, 2026"},
+		{"date layout", `{{date when "15:04"}}`, "10:30"},
+		{"date only", "{{date day 'date'}}", "2026-03-05"},
+		{"date unix", "{{date 0 'rfc3339'}}", "1970-01-01T00:00:00Z"},
+		{"date missing", "{{date missing}}|", "|"},
+	}
+
+	vars := testVariables(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			compiled, err := compileTemplate(tt.template)
+			if err != nil {
+				t.Fatalf("compile %q: %v", tt.template, err)
+			}
+			got, err := compiled.render(vars)
+			if err != nil {
+				t.Fatalf("render %q: %v", tt.template, err)
+			}
+			if got != tt.want {
+				t.Errorf("render %q = %q, want %q", tt.template, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTemplateEngineErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		compile  bool
+	}{
+		{"unclosed tag", "Hi {{name", true},
+		{"unclosed block", "{{#each items}}x", true},
+		{"unexpected close", "x{{/if}}", true},
+		{"mismatched close", "{{#if a}}x{{/each}}", true},
+		{"else outside block", "{{else}}", true},
+		{"double else", "{{#if a}}x{{else}}y{{else}}z{{/if}}", true},
+		{"unknown block", "{{#with user}}x{{/with}}", true},
+		{"block without argument", "{{#if}}x{{/if}}", true},
+		{"unknown helper", "{{shout name}}", true},
+		{"helper arity", "{{truncate name}}", true},
+		{"unterminated string", `{{default name "x}}`, true},
+		{"empty tag", "{{ }}", true},
+		{"truncate bad length", "{{truncate name 'x'}}", false},
+		{"currency bad amount", "{{currency name}}", false},
+		{"date bad value", "{{date name}}", false},
+	}
+
+	vars := testVariables(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			compiled, err := compileTemplate(tt.template)
+			if tt.compile {
+				if err == nil {
+					t.Fatalf("compile %q: want error", tt.template)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("compile %q: %v", tt.template, err)
+			}
+			if _, err := compiled.render(vars); err == nil {
+				t.Fatalf("render %q: want error", tt.template)
+			}
+		})
+	}
+}
+
+func TestTemplateEngineLimits(t *testing.T) {
+	deep := strings.Repeat("{{#if a}}", maxTemplateDepth+1) + strings.Repeat("{{/if}}", maxTemplateDepth+1)
+	if _, err := compileTemplate(deep); err == nil {
+		t.Error("compile: want error for blocks nested too deeply")
+	}
+
+	items := make([]interface{}, 100)
+	compiled, err := compileTemplate("{{#each items}}{{#each ../items}}" + strings.Repeat("x", 10) + "{{/each}}{{/each}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := compiled.render(map[string]interface{}{"items": items}); err != errTemplateTooLarge {
+		t.Errorf("render = %v, want %v", err, errTemplateTooLarge)
+	}
+
+	items = make([]interface{}, 300)
+	compiled, err = compileTemplate("{{#each items}}{{#each ../items}}{{#each ../../items}}{{/each}}{{/each}}{{/each}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := compiled.render(map[string]interface{}{"items": items}); err != errTemplateTooLong {
+		t.Errorf("render = %v, want %v", err, errTemplateTooLong)
+	}
+}
+
+func TestTemplateRendererRecompilesChangedSource(t *testing.T) {
+	renderer := NewTemplateRenderer(0)
+	tpl := &models.Template{Slug: "welcome", Locale: "en", Version: 3}
+	vars := map[string]interface{}{"name": "Ada"}
+
+	for _, tc := range []struct{ source, want string }{
+		{"Hi {{name}}", "Hi Ada"},
+		{"Hi {{name}}", "Hi Ada"},
+		{"Bye {{name}}", "Bye Ada"},
+	} {
+		got, err := renderer.Render(tpl, "body", tc.source, vars)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.want {
+			t.Errorf("Render(%q) = %q, want %q", tc.source, got, tc.want)
+		}
+	}
+
+	if _, err := renderer.Render(tpl, "body", "{{#if x}}", vars); err == nil || !strings.HasPrefix(err.Error(), "body: ") {
+		t.Errorf("Render error = %v, want one naming the field", err)
+	}
+}
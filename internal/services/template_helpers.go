@@ -0,0 +1,176 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type templateHelper struct {
+	minArgs, maxArgs int
+	fn               func(args []interface{}) (interface{}, error)
+}
+
+// templateHelpers are the only functions templates can call.
+var templateHelpers = map[string]templateHelper{
+	// {{upper name}}, {{lower name}}
+	"upper": {1, 1, func(args []interface{}) (interface{}, error) {
+		return strings.ToUpper(formatTemplateValue(args[0])), nil
+	}},
+	"lower": {1, 1, func(args []interface{}) (interface{}, error) {
+		return strings.ToLower(formatTemplateValue(args[0])), nil
+	}},
+	// {{default nickname "there"}}
+	"default": {2, 2, func(args []interface{}) (interface{}, error) {
+		if templateTruthy(args[0]) {
+			return args[0], nil
+		}
+		return args[1], nil
+	}},
+	// {{truncate body 40}} or {{truncate body 40 "..."}}
+	"truncate": {2, 3, truncateHelper},
+	// {{currency total "EUR"}}
+	"currency": {1, 2, currencyHelper},
+	// {{date created_at "long"}}
+	"date": {1, 2, dateHelper},
+}
+
+// truncateHelper cuts a string to at most n user-perceived characters,
+// appending the suffix ("…" by default) when it cut anything.
+func truncateHelper(args []interface{}) (interface{}, error) {
+	s := formatTemplateValue(args[0])
+	n, ok := templateNumber(args[1])
+	if !ok || n < 0 {
+		return nil, errors.New("length must be a non-negative number")
+	}
+	suffix := "…"
+	if len(args) == 3 {
+		suffix = formatTemplateValue(args[2])
+	}
+	if s == "" {
+		return s, nil
+	}
+	bounds := graphemeBoundaries(s)
+	if int(n) >= len(bounds) {
+		return s, nil
+	}
+	return s[:bounds[int(n)]] + suffix, nil
+}
+
+// currencyMinorUnits lists currencies without two decimal places.
+var currencyMinorUnits = map[string]int{"JPY": 0, "KRW": 0, "VND": 0, "CLP": 0, "KWD": 3, "BHD": 3}
+
+var currencySymbols = map[string]string{
+	"USD": "$", "EUR": "€", "GBP": "£", "JPY": "¥", "NGN": "₦", "INR": "₹",
+	"KRW": "₩", "GHS": "GH₵", "KES": "KSh", "ZAR": "R", "CAD": "CA$", "AUD": "A$",
+}
+
+// currencyHelper formats an amount with thousands separators and the
+// currency's symbol, or its code when there is no well known symbol.
+func currencyHelper(args []interface{}) (interface{}, error) {
+	if args[0] == nil {
+		return "", nil
+	}
+	amount, ok := templateNumber(args[0])
+	if !ok {
+		return nil, errors.New("amount must be a number")
+	}
+	var code string
+	if len(args) == 2 {
+		code = strings.ToUpper(formatTemplateValue(args[1]))
+	}
+	decimals, ok := currencyMinorUnits[code]
+	if !ok {
+		decimals = 2
+	}
+
+	formatted := strconv.FormatFloat(math.Abs(amount), 'f', decimals, 64)
+	whole, fraction, _ := strings.Cut(formatted, ".")
+	var b strings.Builder
+	if amount < 0 && strings.Trim(formatted, "0.") != "" {
+		b.WriteByte('-')
+	}
+	if symbol, ok := currencySymbols[code]; ok {
+		b.WriteString(symbol)
+	} else if code != "" {
+		b.WriteString(code + " ")
+	}
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(digit)
+	}
+	if fraction != "" {
+		b.WriteString("." + fraction)
+	}
+	return b.String(), nil
+}
+
+var dateLayouts = map[string]string{
+	"date":     "2006-01-02",
+	"time":     "15:04",
+	"datetime": "2006-01-02 15:04",
+	"short":    "Jan 2",
+	"long":     "January 2, 2006",
+	"rfc3339":  time.RFC3339,
+}
+
+// dateHelper formats an RFC 3339 or YYYY-MM-DD string, or unix seconds, with
+// a named layout from dateLayouts or a Go reference layout.
+func dateHelper(args []interface{}) (interface{}, error) {
+	if args[0] == nil {
+		return "", nil
+	}
+	t, ok := templateTime(args[0])
+	if !ok {
+		return nil, errors.New("value is not a date")
+	}
+	layout := "Jan 2, 2006"
+	if len(args) == 2 {
+		layout = formatTemplateValue(args[1])
+		if named, ok := dateLayouts[layout]; ok {
+			layout = named
+		}
+	}
+	return t.Format(layout), nil
+}
+
+func templateTime(value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, true
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02"} {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t, true
+			}
+		}
+		return time.Time{}, false
+	}
+	if seconds, ok := templateNumber(value); ok {
+		return time.Unix(int64(seconds), 0).UTC(), true
+	}
+	return time.Time{}, false
+}
+
+func templateNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/CyberwizD/Distributed-Notification-System/services/push_service/internal/models"
+)
+
+// InlineTemplatePolicy decides which producers may put the template content
+// in the envelope instead of referencing a stored template. The zero value
+// accepts inline content from every producer.
+type InlineTemplatePolicy struct {
+	// Disabled rejects inline content from every producer.
+	Disabled bool
+	// Producers, when set, are the only producers allowed inline content.
+	Producers []string
+}
+
+// Allows reports whether producer may send inline template content.
+func (p InlineTemplatePolicy) Allows(producer string) bool {
+	if p.Disabled {
+		return false
+	}
+	if len(p.Producers) == 0 {
+		return true
+	}
+	for _, allowed := range p.Producers {
+		if allowed == producer {
+			return true
+		}
+	}
+	return false
+}
+
+// isInlineTemplate reports whether the envelope carries the template content.
+func isInlineTemplate(tpl models.Template) bool {
+	return tpl.Subject != "" || tpl.Body != ""
+}
+
+// inlineTemplate returns the envelope's own template when policy allows
+// producer, the broker-reported publisher, to send one. A rejection is
+// message-fatal.
+func inlineTemplate(envelope *models.MessageEnvelope, producer string, policy InlineTemplatePolicy) (*models.Template, error) {
+	if !policy.Allows(producer) {
+		return nil, &ProviderError{
+			Code:  "InlineTemplateRejected",
+			Class: ErrorMessageFatal,
+			Err:   fmt.Errorf("producer %q may not send inline templates", producer),
+		}
+	}
+	tpl := envelope.Template
+	tpl.Locale = localeFromEnvelope(envelope)
+	return &tpl, nil
+}
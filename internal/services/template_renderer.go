@@ -2,26 +2,74 @@ package services
 
 import (
 	"fmt"
-	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/CyberwizD/Distributed-Notification-System/services/push_service/internal/models"
 )
 
-var placeholderRegex = regexp.MustCompile(`\{\{\s*([a-zA-Z0-9_]+)\s*\}\}`)
-
-// RenderTemplate performs naive moustache-style replacement for {{key}} placeholders.
-func RenderTemplate(template string, variables map[string]interface{}) string {
-	if template == "" || len(variables) == 0 {
-		return template
-	}
-
-	return placeholderRegex.ReplaceAllStringFunc(template, func(match string) string {
-		submatch := placeholderRegex.FindStringSubmatch(match)
-		if len(submatch) != 2 {
-			return match
-		}
-		key := submatch[1]
-		if value, ok := variables[key]; ok {
-			return fmt.Sprint(value)
-		}
-		return match
-	})
+// TemplateRenderer renders template fields with the engine in
+// template_engine.go, compiling each field once per template version.
+type TemplateRenderer struct {
+	maxEntries int
+
+	mu       sync.RWMutex
+	compiled map[string]compiledField
+}
+
+type compiledField struct {
+	source   string
+	template *compiledTemplate
+}
+
+func NewTemplateRenderer(maxEntries int) *TemplateRenderer {
+	if maxEntries <= 0 {
+		maxEntries = 1024
+	}
+	return &TemplateRenderer{
+		maxEntries: maxEntries,
+		compiled:   make(map[string]compiledField),
+	}
+}
+
+// Render renders source, the named field of tpl, against variables.
+func (r *TemplateRenderer) Render(tpl *models.Template, field, source string, variables map[string]interface{}) (string, error) {
+	if !strings.Contains(source, "{{") {
+		return source, nil
+	}
+	compiled, err := r.compile(templateCacheKey(tpl.Slug, tpl.Locale, strconv.Itoa(tpl.Version))+":"+field, source)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", field, err)
+	}
+	out, err := compiled.render(variables)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", field, err)
+	}
+	return out, nil
+}
+
+// compile returns the cached compilation of source under key. The source is
+// compared too, since inline and unversioned templates can change under the
+// same key.
+func (r *TemplateRenderer) compile(key, source string) (*compiledTemplate, error) {
+	r.mu.RLock()
+	entry, ok := r.compiled[key]
+	r.mu.RUnlock()
+	if ok && entry.source == source {
+		return entry.template, nil
+	}
+
+	compiled, err := compileTemplate(source)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	if len(r.compiled) >= r.maxEntries {
+		r.compiled = make(map[string]compiledField)
+	}
+	r.compiled[key] = compiledField{source: source, template: compiled}
+	r.mu.Unlock()
+	return compiled, nil
 }
@@ -1 +1,419 @@
 package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CyberwizD/Distributed-Notification-System/services/push_service/internal/models"
+	"github.com/CyberwizD/Distributed-Notification-System/services/push_service/internal/repository"
+)
+
+const (
+	// webPushRecordSize is the aes128gcm record size; the whole payload must fit
+	// in a single record.
+	webPushRecordSize = 4096
+	// webPushMaxPlaintext is the plaintext RFC 8291 §4 guarantees push
+	// services accept: 4096 bytes of body less the 86 byte aes128gcm header,
+	// the padding delimiter and the 16 byte tag.
+	webPushMaxPlaintext = 3993
+	webPushDefaultTTL   = 24 * time.Hour
+	vapidTokenTTL       = 12 * time.Hour
+)
+
+// WebPushConfig configures the Web Push provider.
+type WebPushConfig struct {
+	// VAPIDPublicKey and VAPIDPrivateKey are base64url encoded; the public key
+	// is the uncompressed P-256 point and the private key the raw scalar.
+	VAPIDPublicKey  string
+	VAPIDPrivateKey string
+	// VAPIDSubject is a mailto: or https: contact URI sent to push services.
+	VAPIDSubject string
+	Timeout      time.Duration
+	// Concurrency bounds the number of in-flight per-subscription requests.
+	Concurrency int
+}
+
+// WebPushProvider delivers encrypted payloads to browser push services using
+// VAPID (RFC 8292) and aes128gcm message encryption (RFC 8291).
+type WebPushProvider struct {
+	vapidKey    *ecdsa.PrivateKey
+	vapidPublic string
+	subject     string
+	client      *http.Client
+	cache       *repository.RedisRepository
+	logger      *slog.Logger
+	concurrency int
+}
+
+func NewWebPushProvider(cfg WebPushConfig, cache *repository.RedisRepository, logger *slog.Logger) (*WebPushProvider, error) {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.VAPIDSubject == "" {
+		return nil, fmt.Errorf("webpush: vapid subject is required")
+	}
+
+	key, err := parseVAPIDPrivateKey(cfg.VAPIDPrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	publicKey, err := key.PublicKey.ECDH()
+	if err != nil {
+		return nil, fmt.Errorf("webpush: invalid vapid key: %w", err)
+	}
+	public := base64.RawURLEncoding.EncodeToString(publicKey.Bytes())
+	if cfg.VAPIDPublicKey != "" && strings.TrimRight(cfg.VAPIDPublicKey, "=") != public {
+		return nil, fmt.Errorf("webpush: vapid public key does not match private key")
+	}
+
+	return &WebPushProvider{
+		vapidKey:    key,
+		vapidPublic: public,
+		subject:     cfg.VAPIDSubject,
+		client: &http.Client{
+			Timeout: cfg.Timeout,
+		},
+		cache:       cache,
+		logger:      logger,
+		concurrency: cfg.Concurrency,
+	}, nil
+}
+
+func parseVAPIDPrivateKey(raw string) (*ecdsa.PrivateKey, error) {
+	d, err := decodeBase64URL(raw)
+	if err != nil {
+		return nil, fmt.Errorf("webpush: decode vapid private key: %w", err)
+	}
+	ecdhKey, err := ecdh.P256().NewPrivateKey(d)
+	if err != nil {
+		return nil, fmt.Errorf("webpush: invalid vapid private key: %w", err)
+	}
+	point := ecdhKey.PublicKey().Bytes()
+	return &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(point[1:33]),
+			Y:     new(big.Int).SetBytes(point[33:]),
+		},
+		D: new(big.Int).SetBytes(d),
+	}, nil
+}
+
+func (p *WebPushProvider) Name() string {
+	return "webpush"
+}
+
+func (p *WebPushProvider) Send(ctx context.Context, payload *PushPayload) ([]models.PushResult, error) {
+	if len(payload.Tokens) == 0 {
+		return nil, fmt.Errorf("webpush: no tokens supplied")
+	}
+
+	plaintext, err := p.plaintext(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := nonEmptyTokens(payload.Tokens)
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("webpush: tokens were empty")
+	}
+	headers := webPushHeaders(payload)
+
+	return sendChunked(ctx, tokens, 1, p.concurrency, func(ctx context.Context, chunk []models.PushToken) ([]models.PushResult, error) {
+		res, err := p.sendOne(ctx, chunk[0], plaintext, headers)
+		if err != nil {
+			return nil, err
+		}
+		return []models.PushResult{res}, nil
+	})
+}
+
+// plaintext is the JSON message handed to the service worker once decrypted.
+func (p *WebPushProvider) plaintext(payload *PushPayload) ([]byte, error) {
+	// The service worker decides what to show; data messages carry no text.
+	message := map[string]interface{}{}
+	if payload.Kind.IsAlert() {
+		message["title"] = payload.Title
+		message["body"] = payload.Body
+		webPushNotification(message, payload.Notification)
+	}
+	if len(payload.Data) > 0 {
+		message["data"] = payload.Data
+	}
+	if overrides := providerOverrides(payload.Overrides, p.Name()); overrides != nil {
+		mergeMaps(message, overrides)
+	}
+	return json.Marshal(message)
+}
+
+// PayloadSize measures the plaintext against what fits in a single aes128gcm
+// record.
+func (p *WebPushProvider) PayloadSize(payload *PushPayload) (int, int, error) {
+	plaintext, err := p.plaintext(payload)
+	if err != nil {
+		return 0, 0, err
+	}
+	return len(plaintext), webPushMaxPlaintext, nil
+}
+
+func (p *WebPushProvider) sendOne(ctx context.Context, token models.PushToken, plaintext []byte, headers http.Header) (models.PushResult, error) {
+	result := models.PushResult{
+		Token:    token.Token,
+		Provider: p.Name(),
+		Status:   models.ResultFailed,
+	}
+
+	if token.Keys == nil || token.Keys.P256dh == "" || token.Keys.Auth == "" {
+		result.Error = "MissingSubscriptionKeys"
+		return result, nil
+	}
+	endpoint, err := url.Parse(token.Token)
+	if err != nil || endpoint.Scheme == "" || endpoint.Host == "" {
+		result.Error = "InvalidEndpoint"
+		return result, nil
+	}
+
+	if len(plaintext) > webPushMaxPlaintext {
+		result.Error = "PayloadTooLarge"
+		return result, nil
+	}
+
+	body, err := encryptWebPush(plaintext, token.Keys.P256dh, token.Keys.Auth)
+	if err != nil {
+		result.Error = "InvalidSubscriptionKeys"
+		p.logger.Debug("webpush encryption failed", slog.Any("error", err))
+		return result, nil
+	}
+
+	authz, err := p.vapidAuthorization(endpoint)
+	if err != nil {
+		return models.PushResult{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, token.Token, bytes.NewReader(body))
+	if err != nil {
+		return models.PushResult{}, err
+	}
+	for key, values := range headers {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Authorization", authz)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return models.PushResult{}, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		result.Status = models.ResultDelivered
+		result.MessageID = resp.Header.Get("Location")
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		result.Error = "SubscriptionGone"
+		if p.cache != nil {
+			if err := p.cache.SuppressToken(ctx, token.Token, 0); err != nil {
+				p.logger.Warn("failed to suppress webpush subscription", slog.Any("error", err))
+			}
+		}
+	case resp.StatusCode == http.StatusTooManyRequests:
+		result.Error = "TooManyRequests"
+		result.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	case resp.StatusCode == http.StatusRequestEntityTooLarge:
+		result.Error = "PayloadTooLarge"
+	default:
+		result.Error = fmt.Sprintf("HTTP_%d", resp.StatusCode)
+		result.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	}
+	return result, nil
+}
+
+// vapidAuthorization builds the "vapid t=..., k=..." header scoped to the
+// push service origin.
+func (p *WebPushProvider) vapidAuthorization(endpoint *url.URL) (string, error) {
+	header := map[string]interface{}{
+		"typ": "JWT",
+		"alg": "ES256",
+	}
+	claims := map[string]interface{}{
+		"aud": endpoint.Scheme + "://" + endpoint.Host,
+		"exp": time.Now().Add(vapidTokenTTL).Unix(),
+		"sub": p.subject,
+	}
+	token, err := signJWT(header, claims, es256Signer(p.vapidKey))
+	if err != nil {
+		return "", fmt.Errorf("webpush: sign vapid token: %w", err)
+	}
+	return fmt.Sprintf("vapid t=%s, k=%s", token, p.vapidPublic), nil
+}
+
+// encryptWebPush encrypts plaintext for a subscription following RFC 8291,
+// returning a single aes128gcm record including the RFC 8188 header.
+func encryptWebPush(plaintext []byte, p256dh, auth string) ([]byte, error) {
+	asPrivate, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return encryptWebPushWith(plaintext, p256dh, auth, asPrivate, salt)
+}
+
+// encryptWebPushWith is encryptWebPush with the sender key pair and salt
+// supplied, which must be fresh for every message.
+func encryptWebPushWith(plaintext []byte, p256dh, auth string, asPrivate *ecdh.PrivateKey, salt []byte) ([]byte, error) {
+	uaPublicRaw, err := decodeBase64URL(p256dh)
+	if err != nil {
+		return nil, fmt.Errorf("decode p256dh: %w", err)
+	}
+	authSecret, err := decodeBase64URL(auth)
+	if err != nil {
+		return nil, fmt.Errorf("decode auth: %w", err)
+	}
+
+	uaPublic, err := ecdh.P256().NewPublicKey(uaPublicRaw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid p256dh: %w", err)
+	}
+	asPublic := asPrivate.PublicKey().Bytes()
+	sharedSecret, err := asPrivate.ECDH(uaPublic)
+	if err != nil {
+		return nil, err
+	}
+
+	// IKM = HKDF(auth_secret, ecdh_secret, "WebPush: info" || 0x00 || ua_public || as_public, 32)
+	keyInfo := append([]byte("WebPush: info\x00"), uaPublicRaw...)
+	keyInfo = append(keyInfo, asPublic...)
+	prkKey, err := hkdf.Extract(sha256.New, sharedSecret, authSecret)
+	if err != nil {
+		return nil, err
+	}
+	ikm, err := hkdf.Expand(sha256.New, prkKey, string(keyInfo), 32)
+	if err != nil {
+		return nil, err
+	}
+
+	prk, err := hkdf.Extract(sha256.New, ikm, salt)
+	if err != nil {
+		return nil, err
+	}
+	cek, err := hkdf.Expand(sha256.New, prk, "Content-Encoding: aes128gcm\x00", 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hkdf.Expand(sha256.New, prk, "Content-Encoding: nonce\x00", 12)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	// A single, final record: plaintext followed by the 0x02 delimiter.
+	record := append(append([]byte{}, plaintext...), 0x02)
+
+	header := make([]byte, 0, 16+4+1+len(asPublic))
+	header = append(header, salt...)
+	header = binary.BigEndian.AppendUint32(header, webPushRecordSize)
+	header = append(header, byte(len(asPublic)))
+	header = append(header, asPublic...)
+
+	return gcm.Seal(header, nonce, record, nil), nil
+}
+
+// decodeBase64URL accepts both padded and unpadded base64url input.
+func decodeBase64URL(value string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+}
+
+// webPushHeaders maps TTL, collapse key and priority onto the RFC 8030 TTL,
+// Topic and Urgency headers.
+func webPushHeaders(payload *PushPayload) http.Header {
+	headers := http.Header{}
+	ttl := webPushDefaultTTL
+	if payload.TTL > 0 {
+		ttl = payload.TTL
+	}
+	headers.Set("TTL", strconv.Itoa(int(ttl/time.Second)))
+	if payload.CollapseKey != "" {
+		headers.Set("Topic", webPushTopic(payload.CollapseKey))
+	}
+	if payload.Priority != "" {
+		headers.Set("Urgency", string(payload.Priority))
+	}
+	return headers
+}
+
+// webPushTopic returns key if it is a valid Topic (at most 32 characters of
+// the base64url alphabet) and a stable 32 character digest of it otherwise.
+func webPushTopic(key string) string {
+	valid := len(key) <= 32
+	for _, r := range key {
+		if !valid {
+			break
+		}
+		valid = r == '-' || r == '_' || (r >= '0' && r <= '9') || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+	}
+	if valid {
+		return key
+	}
+	sum := sha256.Sum256([]byte(key))
+	return base64.RawURLEncoding.EncodeToString(sum[:])[:32]
+}
+
+// webPushNotification adds the fields the service worker passes to
+// showNotification, named after the Notification API options.
+func webPushNotification(message map[string]interface{}, opts models.NotificationOptions) {
+	if opts.ImageURL != "" {
+		message["image"] = opts.ImageURL
+	}
+	if opts.ClickAction != "" {
+		message["url"] = opts.ClickAction
+	}
+	if opts.ThreadID != "" {
+		message["tag"] = opts.ThreadID
+	}
+	if len(opts.Actions) > 0 {
+		actions := make([]map[string]string, 0, len(opts.Actions))
+		for _, action := range opts.Actions {
+			entry := map[string]string{"action": action.ID, "title": action.Title}
+			if action.Icon != "" {
+				entry["icon"] = action.Icon
+			}
+			if action.URL != "" {
+				entry["url"] = action.URL
+			}
+			actions = append(actions, entry)
+		}
+		message["actions"] = actions
+	}
+}
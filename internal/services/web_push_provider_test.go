@@ -0,0 +1,64 @@
+package services
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"testing"
+)
+
+// TestEncryptWebPushRFC8291 checks the encryption against the example in
+// RFC 8291 Appendix A.
+func TestEncryptWebPushRFC8291(t *testing.T) {
+	const (
+		plaintext  = "When I grow up, I want to be a watermelon"
+		asPrivate  = "yfWPiYE-n46HLnH0KqZOF1fJJU3MYrct3AELtAQ-oRw"
+		uaPublic   = "BCVxsr7N_eNgVRqvHtD0zTZsEc6-VV-JvLexhqUzORcxaOzi6-AYWXvTBHm4bjyPjs7Vd8pZGH6SRpkNtoIAiw4"
+		authSecret = "BTBZMqHH6r4Tts7J_aSIgg"
+		salt       = "DGv6ra1nlYgDCS1FRnbzlw"
+		want       = "DGv6ra1nlYgDCS1FRnbzlwAAEABBBP4z9KsN6nGRTbVYI_c7VJSPQTBtkgcy27mlmlMoZIIgDll6e3vCYLocInmYWAmS6TlzAC8wEqKK6PBru3jl7A_yl95bQpu6cVPTpK4Mqgkf1CXztLVBSt2Ks3oZwbuwXPXLWyouBWLVWGNWQexSgSxsj_Qulcy4a-fN"
+	)
+
+	rawPrivate, err := decodeBase64URL(asPrivate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ecdh.P256().NewPrivateKey(rawPrivate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rawSalt, err := decodeBase64URL(salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantBody, err := decodeBase64URL(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := encryptWebPushWith([]byte(plaintext), uaPublic, authSecret, key, rawSalt)
+	if err != nil {
+		t.Fatalf("encryptWebPushWith: %v", err)
+	}
+	if !bytes.Equal(body, wantBody) {
+		t.Fatalf("body mismatch\n got %x\nwant %x", body, wantBody)
+	}
+}
+
+// TestWebPushMaxPlaintext checks that the largest accepted plaintext fills
+// exactly the 4096 bytes push services must accept.
+func TestWebPushMaxPlaintext(t *testing.T) {
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uaPublic := base64.RawURLEncoding.EncodeToString(key.PublicKey().Bytes())
+	body, err := encryptWebPush(make([]byte, webPushMaxPlaintext), uaPublic, "BTBZMqHH6r4Tts7J_aSIgg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(body) != 4096 {
+		t.Fatalf("body is %d bytes, want 4096", len(body))
+	}
+}
@@ -12,6 +12,9 @@ type Metrics struct {
 	delivered atomic.Int64
 	failed    atomic.Int64
 	retried   atomic.Int64
+	fallbacks atomic.Int64
+	dryRuns   atomic.Int64
+	expired   atomic.Int64
 }
 
 // New returns a zeroed Metrics collector.
@@ -23,6 +26,9 @@ func (m *Metrics) IncConsumed()  { m.consumed.Add(1) }
 func (m *Metrics) IncDelivered() { m.delivered.Add(1) }
 func (m *Metrics) IncFailed()    { m.failed.Add(1) }
 func (m *Metrics) IncRetried()   { m.retried.Add(1) }
+func (m *Metrics) IncFallback()  { m.fallbacks.Add(1) }
+func (m *Metrics) IncDryRun()    { m.dryRuns.Add(1) }
+func (m *Metrics) IncExpired()   { m.expired.Add(1) }
 
 // Handler exposes the counters via a very small JSON response so we do not
 // need to pull in a heavy metrics dependency for the assignment.
@@ -33,7 +39,10 @@ func (m *Metrics) Handler() http.Handler {
   "consumed": ` + itoa(m.consumed.Load()) + `,
   "delivered": ` + itoa(m.delivered.Load()) + `,
   "failed": ` + itoa(m.failed.Load()) + `,
-  "retried": ` + itoa(m.retried.Load()) + `
+  "retried": ` + itoa(m.retried.Load()) + `,
+  "fallbacks": ` + itoa(m.fallbacks.Load()) + `,
+  "dry_runs": ` + itoa(m.dryRuns.Load()) + `,
+  "expired": ` + itoa(m.expired.Load()) + `
 }`))
 	})
 }
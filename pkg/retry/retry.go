@@ -15,8 +15,10 @@ type Config struct {
 	JitterFactor   float64
 }
 
-// Do executes fn and retries with exponential backoff until it succeeds or the
-// context is cancelled.
+// Do executes fn and retries with exponential backoff until it succeeds, the
+// context is cancelled or fn returns an error that is not Retryable. An error
+// carrying a RetryAfter delay replaces the computed backoff for that attempt,
+// still capped by MaxBackoff.
 func Do(ctx context.Context, cfg Config, fn func() error) error {
 	if cfg.MaxAttempts <= 0 {
 		cfg.MaxAttempts = 1
@@ -42,11 +44,14 @@ func Do(ctx context.Context, cfg Config, fn func() error) error {
 			return nil
 		}
 
-		if attempt == cfg.MaxAttempts {
+		if attempt == cfg.MaxAttempts || !Retryable(err) {
 			break
 		}
 
 		sleep := applyJitter(backoff, cfg.JitterFactor)
+		if requested, ok := RetryAfter(err); ok {
+			sleep = requested
+		}
 		if sleep > cfg.MaxBackoff {
 			sleep = cfg.MaxBackoff
 		}
@@ -69,6 +74,60 @@ func Do(ctx context.Context, cfg Config, fn func() error) error {
 	return err
 }
 
+// Retryable reports whether err is worth retrying. Errors opt out by
+// implementing Retryable() bool; unclassified errors are retried, and a joined
+// error is retried if any part of it is.
+func Retryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if classified, ok := err.(interface{ Retryable() bool }); ok {
+		return classified.Retryable()
+	}
+	switch wrapped := err.(type) {
+	case interface{ Unwrap() []error }:
+		for _, inner := range wrapped.Unwrap() {
+			if Retryable(inner) {
+				return true
+			}
+		}
+		return false
+	case interface{ Unwrap() error }:
+		return Retryable(wrapped.Unwrap())
+	}
+	return true
+}
+
+// RetryAfter returns the delay requested by err through a RetryAfter()
+// time.Duration method. For joined errors the longest request wins.
+func RetryAfter(err error) (time.Duration, bool) {
+	if err == nil {
+		return 0, false
+	}
+	if delayed, ok := err.(interface{ RetryAfter() time.Duration }); ok {
+		if d := delayed.RetryAfter(); d > 0 {
+			return d, true
+		}
+		return 0, false
+	}
+	switch wrapped := err.(type) {
+	case interface{ Unwrap() []error }:
+		var (
+			longest time.Duration
+			found   bool
+		)
+		for _, inner := range wrapped.Unwrap() {
+			if d, ok := RetryAfter(inner); ok && d > longest {
+				longest, found = d, true
+			}
+		}
+		return longest, found
+	case interface{ Unwrap() error }:
+		return RetryAfter(wrapped.Unwrap())
+	}
+	return 0, false
+}
+
 func applyJitter(duration time.Duration, factor float64) time.Duration {
 	if factor <= 0 {
 		return duration
@@ -0,0 +1,125 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// classifiedError is an error that opts in or out of retries and may request
+// a delay.
+type classifiedError struct {
+	retryable bool
+	wait      time.Duration
+}
+
+func (e classifiedError) Error() string {
+	return fmt.Sprintf("retryable=%v wait=%s", e.retryable, e.wait)
+}
+func (e classifiedError) Retryable() bool           { return e.retryable }
+func (e classifiedError) RetryAfter() time.Duration { return e.wait }
+
+func TestRetryable(t *testing.T) {
+	fatal := classifiedError{retryable: false}
+	transient := classifiedError{retryable: true}
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"unclassified", errors.New("connection reset"), true},
+		{"non-retryable", fatal, false},
+		{"wrapped non-retryable", fmt.Errorf("send: %w", fatal), false},
+		{"joined with a retryable part", errors.Join(fatal, transient), true},
+		{"joined with an unclassified part", errors.Join(fatal, errors.New("timeout")), true},
+		{"joined non-retryable", errors.Join(fatal, fatal), false},
+	}
+	for _, tc := range tests {
+		if got := Retryable(tc.err); got != tc.want {
+			t.Errorf("%s: Retryable = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		want   time.Duration
+		wantOK bool
+	}{
+		{"nil", nil, 0, false},
+		{"no delay", errors.New("boom"), 0, false},
+		{"zero delay", classifiedError{retryable: true}, 0, false},
+		{"delay", classifiedError{retryable: true, wait: time.Second}, time.Second, true},
+		{"wrapped delay", fmt.Errorf("send: %w", classifiedError{wait: 2 * time.Second}), 2 * time.Second, true},
+		{"joined takes the longest", errors.Join(
+			classifiedError{wait: time.Second},
+			errors.New("boom"),
+			classifiedError{wait: 3 * time.Second},
+			classifiedError{wait: 2 * time.Second},
+		), 3 * time.Second, true},
+	}
+	for _, tc := range tests {
+		got, ok := RetryAfter(tc.err)
+		if got != tc.want || ok != tc.wantOK {
+			t.Errorf("%s: RetryAfter = %s, %v, want %s, %v", tc.name, got, ok, tc.want, tc.wantOK)
+		}
+	}
+}
+
+func TestDoStopsOnNonRetryable(t *testing.T) {
+	calls := 0
+	err := Do(context.Background(), Config{MaxAttempts: 5, InitialBackoff: time.Millisecond}, func() error {
+		calls++
+		return classifiedError{retryable: false}
+	})
+	if err == nil || calls != 1 {
+		t.Errorf("Do = %v after %d calls, want the error after 1 call", err, calls)
+	}
+}
+
+func TestDoRetriesUntilSuccess(t *testing.T) {
+	calls := 0
+	err := Do(context.Background(), Config{MaxAttempts: 5, InitialBackoff: time.Millisecond}, func() error {
+		calls++
+		if calls < 3 {
+			return errors.New("timeout")
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("Do = %v after %d calls, want success after 3 calls", err, calls)
+	}
+}
+
+func TestDoCapsRetryAfterAtMaxBackoff(t *testing.T) {
+	calls := 0
+	start := time.Now()
+	err := Do(context.Background(), Config{MaxAttempts: 2, InitialBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond}, func() error {
+		calls++
+		return classifiedError{retryable: true, wait: time.Hour}
+	})
+	if err == nil || calls != 2 {
+		t.Fatalf("Do = %v after %d calls, want the error after 2 calls", err, calls)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Do waited %s, want the hour long Retry-After capped at MaxBackoff", elapsed)
+	}
+}
+
+func TestDoStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	err := Do(ctx, Config{MaxAttempts: 5, InitialBackoff: time.Hour, MaxBackoff: time.Hour}, func() error {
+		calls++
+		cancel()
+		return errors.New("timeout")
+	})
+	if !errors.Is(err, context.Canceled) || calls != 1 {
+		t.Errorf("Do = %v after %d calls, want context.Canceled after 1 call", err, calls)
+	}
+}